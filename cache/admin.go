@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"encoding/json"
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Hits returns how often this entry was served by a cache since it was added.
+func (e *Entry) Hits() int64 {
+	if e.hits == nil {
+		return 0
+	}
+	return atomic.LoadInt64(e.hits)
+}
+
+// AdminHandler returns a handler for inspecting and administrating this cache.
+// The handler has no access control of its own, so it must be mounted behind authentication.
+//
+// GET lists the entries, as JSON if the client prefers application/json or the query parameter format is "json", otherwise as HTML.
+// The query parameter prefix restricts the list to entries whose URI starts with it.
+//
+// POST performs the action given by the form value action, and responds with its result as JSON,
+// or redirects HTML clients back to the list:
+//
+//	action=purge&uri=…          removes the entry with the given URI
+//	action=purge&prefix=…       removes all entries whose URI starts with the given prefix
+//	action=purge-all            removes all entries
+//	action=reload               reloads file-backed entries, see Reload
+//
+// Cross-site POST requests are rejected based on the Sec-Fetch-Site request header field.
+func (c *Cache) AdminHandler() http.Handler {
+	return &adminHandler{cache: c}
+}
+
+func AdminHandler() http.Handler {
+	return GlobalCache.AdminHandler()
+}
+
+// adminHandler serves the administration of a cache.
+type adminHandler struct {
+	cache *Cache
+}
+
+// entryInfo describes an entry in the listing of the adminHandler.
+type entryInfo struct {
+	URI          string         `json:"uri"`
+	ContentType  string         `json:"contentType"`
+	Sizes        map[string]int `json:"sizes"`
+	ETag         string         `json:"etag"`
+	LastModified *time.Time     `json:"lastModified,omitempty"`
+	MaxAge       int64          `json:"maxAge"`
+	Hits         int64          `json:"hits"`
+	Filename     string         `json:"filename,omitempty"`
+	Pinned       bool           `json:"pinned,omitempty"`
+	Variants     int            `json:"variants,omitempty"`
+}
+
+// adminListing is the listing of the adminHandler.
+type adminListing struct {
+	Prefix  string      `json:"prefix,omitempty"`
+	Entries []entryInfo `json:"entries"`
+	Count   int         `json:"count"`
+	Bytes   int         `json:"bytes"`
+}
+
+// adminResult is the result of an action of the adminHandler.
+type adminResult struct {
+	Removed []string       `json:"removed,omitempty"`
+	Reload  *ReloadSummary `json:"reload,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.list(w, r)
+	case http.MethodPost:
+		h.act(w, r)
+	default:
+		w.Header().Set(header.Allow, strings.Join([]string{http.MethodGet, http.MethodHead, http.MethodPost}, ", "))
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// list responds with the listing of the entries.
+func (h *adminHandler) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	listing := adminListing{Prefix: prefix, Entries: []entryInfo{}}
+	listing.Count, listing.Bytes = h.cache.Size()
+	for _, entry := range h.cache.Entries() {
+		if strings.HasPrefix(entry.URI, prefix) {
+			listing.Entries = append(listing.Entries, entry.info(h.cache))
+		}
+	}
+	w.Header().Set(header.CacheControl, "no-store")
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, listing)
+		return
+	}
+	w.Header().Set(header.ContentType, mimetype.TextHtml+"; charset=utf-8")
+	// An error means that the client went away, so there is nobody left to tell.
+	_ = adminTemplate.Execute(w, listing)
+}
+
+// act performs the action of a POST request.
+func (h *adminHandler) act(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(secFetchSite) == "cross-site" {
+		writeJSON(w, http.StatusForbidden, adminResult{Error: "cross-site request"})
+		return
+	}
+	var result adminResult
+	status := http.StatusOK
+	switch action := r.FormValue("action"); {
+	case action == "purge" && r.FormValue("uri") != "":
+		if uri := strings.TrimPrefix(r.FormValue("uri"), "/"); h.cache.Remove(uri) {
+			result.Removed = []string{uri}
+		}
+	case action == "purge" && r.FormValue("prefix") != "":
+		result.Removed = h.cache.RemovePrefix(strings.TrimPrefix(r.FormValue("prefix"), "/"))
+	case action == "purge-all":
+		result.Removed = h.cache.RemovePrefix("")
+	case action == "reload":
+		summary, err := h.cache.Reload()
+		result.Reload = &summary
+		if err != nil {
+			result.Error = err.Error()
+			status = http.StatusInternalServerError
+		}
+	default:
+		result.Error = "unknown action"
+		status = http.StatusBadRequest
+	}
+	if !wantsJSON(r) && status == http.StatusOK {
+		http.Redirect(w, r, r.RequestURI, http.StatusSeeOther)
+		return
+	}
+	writeJSON(w, status, result)
+}
+
+// The request header field of the Fetch Metadata Request Headers with the relation between the origins of the initiator and the target.
+const secFetchSite = "Sec-Fetch-Site"
+
+// info returns the description of this entry in cache c for the adminHandler.
+func (e *Entry) info(c *Cache) entryInfo {
+	info := entryInfo{
+		URI:          e.URI,
+		ContentType:  e.ContentType,
+		Sizes:        make(map[string]int),
+		ETag:         e.ETag,
+		LastModified: e.LastModified,
+		MaxAge:       int64(c.maxAge(e) / time.Second),
+		Hits:         e.Hits(),
+		Filename:     e.Filename,
+		Pinned:       e.Pinned,
+		Variants:     len(e.Variants),
+	}
+	for _, enc := range e.encodings() {
+		info.Sizes[enc.coding] = len(enc.body)
+	}
+	info.Sizes[Identity] = int(e.bodySize())
+	return info
+}
+
+// wantsJSON returns true if the client of r prefers JSON over HTML.
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "json"
+	}
+	accept := parseQualityList(r.Header.Values(header.Accept))
+	return accept != nil && mediaTypeQuality(accept, mimetype.ApplicationJson) > mediaTypeQuality(accept, mimetype.TextHtml)
+}
+
+// writeJSON responds with v as JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Set(header.ContentType, mimetype.ApplicationJson)
+	w.WriteHeader(status)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+var adminTemplate = template.Must(template.New("admin").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Cache</title></head>
+<body>
+<h1>Cache</h1>
+<p>{{.Count}} entries, {{.Bytes}} bytes.</p>
+<form method="get"><input name="prefix" value="{{.Prefix}}" placeholder="prefix"> <button>Filter</button></form>
+<form method="post"><input type="hidden" name="action" value="purge"><input name="prefix" value="{{.Prefix}}" placeholder="prefix"> <button>Purge prefix</button></form>
+<form method="post"><input type="hidden" name="action" value="purge-all"><button>Purge all</button></form>
+<form method="post"><input type="hidden" name="action" value="reload"><button>Reload</button></form>
+<table>
+<tr><th>URI</th><th>Content-Type</th><th>Sizes</th><th>ETag</th><th>Last-Modified</th><th>Max-Age</th><th>Hits</th><th></th></tr>
+{{range .Entries}}<tr><td>{{.URI}}</td><td>{{.ContentType}}</td><td>{{range $coding, $size := .Sizes}}{{$coding}}: {{$size}}<br>{{end}}</td><td>{{.ETag}}</td><td>{{with .LastModified}}{{.Format "2006-01-02 15:04:05 MST"}}{{end}}</td><td>{{.MaxAge}}</td><td>{{.Hits}}</td><td><form method="post"><input type="hidden" name="action" value="purge"><input type="hidden" name="uri" value="{{.URI}}"><button>Purge</button></form></td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
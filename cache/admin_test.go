@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"encoding/json"
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAdminCache() *Cache {
+	c := New()
+	for _, uri := range []string{"a", "docs/b", "docs/c"} {
+		c.Add(&Entry{URI: uri, Body: []byte("x"), ContentType: "text/plain"})
+	}
+	return c
+}
+
+func TestAdminList(t *testing.T) {
+	c := newAdminCache()
+	doRequest(c.CacheHandler(nil), http.MethodGet, "/docs/b")
+	handler := c.AdminHandler()
+
+	w := doRequest(handler, http.MethodGet, "/?prefix=docs/", header.Accept, mimetype.ApplicationJson)
+	var listing adminListing
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("got %s: %v", w.Body.String(), err)
+	}
+	if listing.Count != 3 || len(listing.Entries) != 2 || listing.Entries[0].URI != "docs/b" || listing.Entries[0].Hits != 1 || listing.Entries[0].Sizes[Identity] != 1 {
+		t.Errorf("got listing %+v", listing)
+	}
+	if got := w.Header().Get(header.CacheControl); got != "no-store" {
+		t.Errorf("got Cache-Control %q", got)
+	}
+
+	w = doRequest(handler, http.MethodGet, "/", header.Accept, "text/html,application/json;q=0.9")
+	if !strings.HasPrefix(w.Header().Get(header.ContentType), mimetype.TextHtml) || !strings.Contains(w.Body.String(), "<td>docs/c</td>") {
+		t.Errorf("got %v %s", w.Header(), w.Body.String())
+	}
+	if w := doRequest(handler, http.MethodGet, "/?format=json", header.Accept, mimetype.TextHtml); w.Header().Get(header.ContentType) != mimetype.ApplicationJson {
+		t.Errorf("got Content-Type %q with format=json", w.Header().Get(header.ContentType))
+	}
+}
+
+func TestAdminActions(t *testing.T) {
+	tests := []struct {
+		query   string
+		status  int
+		removed string
+		remains string
+	}{
+		{"action=purge&uri=/docs/b", http.StatusOK, "docs/b", "a docs/c"},
+		{"action=purge&prefix=docs/", http.StatusOK, "docs/b docs/c", "a"},
+		{"action=purge-all", http.StatusOK, "a docs/b docs/c", ""},
+		{"action=unknown", http.StatusBadRequest, "", "a docs/b docs/c"},
+	}
+	for _, test := range tests {
+		c := newAdminCache()
+		w := doRequest(c.AdminHandler(), http.MethodPost, "/?format=json&"+test.query)
+		var result adminResult
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: got %s: %v", test.query, w.Body.String(), err)
+		}
+		if w.Code != test.status || strings.Join(result.Removed, " ") != test.removed {
+			t.Errorf("%s: got %d %+v", test.query, w.Code, result)
+		}
+		if got := cachedURIs(c); got != test.remains {
+			t.Errorf("%s: got entries %q, want %q", test.query, got, test.remains)
+		}
+	}
+}
+
+func TestAdminRedirectsHTMLClients(t *testing.T) {
+	c := newAdminCache()
+	w := doRequest(c.AdminHandler(), http.MethodPost, "/?action=purge-all", header.Accept, mimetype.TextHtml)
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("got status %d", w.Code)
+	}
+}
+
+func TestAdminRejectsCrossSite(t *testing.T) {
+	c := newAdminCache()
+	w := doRequest(c.AdminHandler(), http.MethodPost, "/?action=purge-all", secFetchSite, "cross-site")
+	if w.Code != http.StatusForbidden || cachedURIs(c) != "a docs/b docs/c" {
+		t.Errorf("got status %d, entries %q", w.Code, cachedURIs(c))
+	}
+}
+
+func TestAdminMethodNotAllowed(t *testing.T) {
+	c := newAdminCache()
+	if w := doRequest(c.AdminHandler(), http.MethodDelete, "/"); w.Code != http.StatusMethodNotAllowed || w.Header().Get(header.Allow) != "GET, HEAD, POST" {
+		t.Errorf("got %d, Allow %q", w.Code, w.Header().Get(header.Allow))
+	}
+}
+
+func TestAdminListEffectiveMaxAge(t *testing.T) {
+	c := newAdminCache()
+	c.SetCacheControl(CacheControl{MaxAge: time.Minute}, nil)
+	asset := c.Fingerprint(&Entry{URI: "app.js", Body: []byte("v1"), ContentType: "text/javascript"})
+	w := doRequest(c.AdminHandler(), http.MethodGet, "/?format=json")
+	var listing adminListing
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("got %s: %v", w.Body.String(), err)
+	}
+	maxAges := map[string]int64{}
+	for _, entry := range listing.Entries {
+		maxAges[entry.URI] = entry.MaxAge
+	}
+	if maxAges[asset] != 31536000 || maxAges["a"] != 60 {
+		t.Errorf("got max-age %v", maxAges)
+	}
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"sort"
+)
+
+// The version of the archive format written by WriteArchive.
+const archiveVersion = 1
+
+// ArchiveOptions configures WriteArchive.
+type ArchiveOptions struct {
+
+	// BestCompression recompresses the bodies at the best compression levels of gzip, Brotli and Zstandard.
+	// This is slow, and meant for build time, see cmd/cachepack.
+	BestCompression bool
+}
+
+// archive is the content of an archive written by WriteArchive.
+type archive struct {
+	Version int
+	Entries []archiveEntry
+}
+
+// archiveEntry is an entry in an archive.
+type archiveEntry struct {
+	Entry *Entry
+
+	// The logical name of a fingerprinted entry, see Fingerprint, or empty.
+	Asset string
+
+	// The SHA-256 digests of the entry and its variants by content coding, depth first.
+	Digests []map[string][]byte
+}
+
+// WriteArchive writes all entries of this cache to w, with their compressed bodies, ETags, Subresource Integrity values and digests,
+// so that LoadArchive can add them to a cache without compressing or hashing them again.
+// The entries are written in the order of their URIs, and without Filename, so that Reload leaves them alone.
+// File-backed entries are read and compressed, and become entries with their body in memory.
+// Entries stored by a Proxy or Memoize are not written.
+func (c *Cache) WriteArchive(w io.Writer, options ArchiveOptions) error {
+	entries := c.Entries()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URI < entries[j].URI })
+	a := archive{Version: archiveVersion}
+	for _, entry := range entries {
+		if entry.freshness != nil {
+			continue
+		}
+		archived, err := entry.archived(options)
+		if err != nil {
+			return err
+		}
+		a.Entries = append(a.Entries, archiveEntry{Entry: archived, Asset: entry.asset, Digests: archived.archivedDigests()})
+	}
+	return gob.NewEncoder(w).Encode(&a)
+}
+
+func WriteArchive(w io.Writer, options ArchiveOptions) error {
+	return GlobalCache.WriteArchive(w, options)
+}
+
+// archived returns a copy of this entry and its variants for an archive.
+func (e *Entry) archived(options ArchiveOptions) (*Entry, error) {
+	archived := *e
+	archived.Filename = ""
+	if e.file != nil {
+		body, err := e.readBody()
+		if err != nil {
+			return nil, err
+		}
+		archived.Body = body
+		archived.file = nil
+		archived.digests = nil
+		archived.prepare()
+	}
+	if options.BestCompression {
+		archived.GzipBody = compressGzipLevel(archived.Body, gzip.BestCompression)
+		archived.BrotliBody = compressBrotliLevel(archived.Body, brotli.BestCompression)
+		archived.ZstdBody = compressZstdLevel(archived.Body, zstd.SpeedBestCompression)
+		archived.digests = archived.computeDigests()
+	}
+	archived.Variants = make([]*Entry, len(e.Variants))
+	for i, variant := range e.Variants {
+		var err error
+		if archived.Variants[i], err = variant.archived(options); err != nil {
+			return nil, err
+		}
+	}
+	return &archived, nil
+}
+
+// archivedDigests returns the digests of this entry and its variants, depth first.
+func (e *Entry) archivedDigests() []map[string][]byte {
+	digests := []map[string][]byte{e.digests}
+	for _, variant := range e.Variants {
+		digests = append(digests, variant.archivedDigests()...)
+	}
+	return digests
+}
+
+// restoreDigests sets the digests of this entry and its variants from the digests of an archive entry,
+// and returns the digests which remain for the following variants.
+// Missing digests, as in archives written before digests were archived, are computed by prepare.
+func (e *Entry) restoreDigests(digests []map[string][]byte) []map[string][]byte {
+	if len(digests) == 0 {
+		return nil
+	}
+	if len(digests[0]) > 0 {
+		e.digests = digests[0]
+	}
+	digests = digests[1:]
+	for _, variant := range e.Variants {
+		digests = variant.restoreDigests(digests)
+	}
+	return digests
+}
+
+// LoadArchive adds the entries of an archive written by WriteArchive, for example one embedded with go:embed.
+// Fingerprinted entries keep their logical names, see AssetURI.
+// If reading the archive fails, no entries are added, and the error is returned.
+func (c *Cache) LoadArchive(r io.Reader) (LoadSummary, error) {
+	var summary LoadSummary
+	var a archive
+	if err := gob.NewDecoder(r).Decode(&a); err != nil {
+		return summary, err
+	}
+	if a.Version != archiveVersion {
+		return summary, fmt.Errorf("unsupported cache archive version %d", a.Version)
+	}
+	for _, archived := range a.Entries {
+		entry := archived.Entry
+		entry.restoreDigests(archived.Digests)
+		if archived.Asset != "" {
+			entry.asset = archived.Asset
+			c.addAsset(entry)
+		} else {
+			c.Add(entry)
+		}
+		summary.URIs = append(summary.URIs, entry.URI)
+		summary.Bytes += int64(len(entry.Body))
+	}
+	return summary, nil
+}
+
+func LoadArchive(r io.Reader) (LoadSummary, error) {
+	return GlobalCache.LoadArchive(r)
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// roundTrip writes the entries of c to an archive and loads it into a new cache.
+func roundTrip(t *testing.T, c *Cache, options ArchiveOptions) *Cache {
+	t.Helper()
+	var b bytes.Buffer
+	if err := c.WriteArchive(&b, options); err != nil {
+		t.Fatal(err)
+	}
+	loaded := New()
+	if _, err := loaded.LoadArchive(&b); err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "file.txt", "from a file", time.Now().Add(-time.Hour))
+	c := New()
+	if _, err := c.LoadDir(dir, LoadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	c.Add(&Entry{URI: "a", Body: []byte("hello hello hello"), ContentType: "text/plain", Variants: []*Entry{{URI: "a.de", Body: []byte("hallo"), ContentType: "text/plain", Language: "de"}}})
+	asset := c.Fingerprint(&Entry{URI: "app.js", Body: []byte("v1"), ContentType: "text/javascript"})
+
+	for _, options := range []ArchiveOptions{{}, {BestCompression: true}} {
+		loaded := roundTrip(t, c, options)
+		if got := cachedURIs(loaded); got != "a "+asset+" file.txt" {
+			t.Errorf("%+v: got entries %q", options, got)
+		}
+		if got, ok := loaded.AssetURI("app.js"); !ok || got != asset {
+			t.Errorf("%+v: got AssetURI %q, %v", options, got, ok)
+		}
+		original, _ := c.Get("a")
+		entry, _ := loaded.Get("a")
+		if entry.ETag != original.ETag || entry.Integrity[SHA384] != original.Integrity[SHA384] {
+			t.Errorf("%+v: got ETag %s, integrity %s", options, entry.ETag, entry.Integrity[SHA384])
+		}
+		file, _ := loaded.Get("file.txt")
+		if file.Filename != "" || string(file.Body) != "from a file" {
+			t.Errorf("%+v: got Filename %q, body %q", options, file.Filename, file.Body)
+		}
+		w := doRequest(loaded.CacheHandler(nil), http.MethodGet, "/a", header.AcceptEncoding, Brotli, header.AcceptLanguage, "de")
+		if w.Header().Get(header.ContentEncoding) != Brotli {
+			t.Errorf("%+v: got Content-Encoding %q", options, w.Header().Get(header.ContentEncoding))
+		}
+	}
+}
+
+func TestArchiveDigests(t *testing.T) {
+	c := New()
+	c.Add(&Entry{URI: "a", Body: []byte("hello hello hello"), ContentType: "text/plain", Variants: []*Entry{{URI: "a.de", Body: []byte("hallo"), ContentType: "text/plain", Language: "de"}}})
+	c.Add(&Entry{URI: "empty", Body: []byte{}, ContentType: "text/plain"})
+	for _, options := range []ArchiveOptions{{}, {BestCompression: true}} {
+		loaded := roundTrip(t, c, options)
+		for _, uri := range []string{"a", "empty"} {
+			entry, _ := loaded.Get(uri)
+			if !reflect.DeepEqual(entry.digests, entry.computeDigests()) {
+				t.Errorf("%+v: %s has digests %v", options, uri, entry.digests)
+			}
+			for _, variant := range entry.Variants {
+				if variant.digests == nil || !reflect.DeepEqual(variant.digests, variant.computeDigests()) {
+					t.Errorf("%+v: the variant of %s has digests %v", options, uri, variant.digests)
+				}
+			}
+		}
+	}
+}
+
+func TestLoadArchiveRestoresDigests(t *testing.T) {
+	variant := &Entry{URI: "a.de", Body: []byte("hallo"), ContentType: "text/plain", Language: "de"}
+	entry := &Entry{URI: "a", Body: []byte("hello"), ContentType: "text/plain", Variants: []*Entry{variant}}
+	archived := map[string][]byte{Identity: []byte("archived")}
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(&archive{Version: archiveVersion, Entries: []archiveEntry{{Entry: entry, Digests: []map[string][]byte{{}, archived}}}}); err != nil {
+		t.Fatal(err)
+	}
+	c := New()
+	if _, err := c.LoadArchive(&b); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ := c.Get("a")
+	if !reflect.DeepEqual(loaded.digests, loaded.computeDigests()) {
+		t.Errorf("got digests %v, want computed digests for a missing entry in the archive", loaded.digests)
+	}
+	if !reflect.DeepEqual(loaded.Variants[0].digests, archived) {
+		t.Errorf("got variant digests %v, want the archived digests", loaded.Variants[0].digests)
+	}
+}
+
+func TestLoadArchiveVersion(t *testing.T) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(&archive{Version: archiveVersion + 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New().LoadArchive(&b); err == nil {
+		t.Error("got no error for an unsupported version")
+	}
+}
@@ -5,19 +5,30 @@ import (
 	"compress/gzip"
 	"crypto/md5"
 	"encoding/hex"
-	"fmt"
 	"github.com/nelkinda/http-go/header"
-	"github.com/nelkinda/http-go/mimetype"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	// Gzip is the constant for the Content-Encoding "gzip".
 	Gzip = "gzip"
+
+	// Brotli is the constant for the Content-Encoding "br".
+	Brotli = "br"
+
+	// Zstd is the constant for the Content-Encoding "zstd".
+	Zstd = "zstd"
+
+	// Identity is the constant for the Content-Encoding "identity", meaning no compression.
+	Identity = "identity"
 )
 
 // Entry describes a single entry in the cache.
@@ -25,110 +36,364 @@ type Entry struct {
 
 	// The URI to which this entry is mapped.
 	// This is the same as the key in the cache.
-	URI          string
+	URI string
 
 	// The uncompressed response body.
-	Body         []byte
+	Body []byte
 
 	// The GZip compressed response body.
-	GzipBody     []byte
+	GzipBody []byte
+
+	// The Brotli compressed response body.
+	BrotliBody []byte
+
+	// The Zstandard compressed response body.
+	ZstdBody []byte
 
 	// The Content-Type of the body.
-	ContentType  string
+	ContentType string
 
 	// The Last-Modified timestamp of the body.
 	LastModified *time.Time
 
 	// The maximum cache age of the body.
-	MaxAge       time.Duration
+	// It is used if the cache control policy of this entry has no MaxAge.
+	MaxAge time.Duration
+
+	// The cache control policy of this entry.
+	// If nil, the policy is taken from the cache, see Cache.SetCacheControl.
+	CacheControl *CacheControl
 
 	// The ETag of the body, if available.
-	ETag         string
+	ETag string
+
+	// The Subresource Integrity values of the body by hash algorithm, like SHA384.
+	// They are computed when the entry is added.
+	Integrity map[string]string
+
+	// The file from which the body was loaded, if any.
+	// Entries with a Filename are updated by Reload and Watch when the file changes.
+	Filename string
+
+	// Pinned entries are never evicted, see Limits.
+	Pinned bool
+
+	// Additional header fields sent with the body, if any.
+	Header http.Header
+
+	// The natural language of the body as language tag, like "en" or "de-CH", sent as Content-Language.
+	Language string
+
+	// Alternative representations of this entry, like translations or other media types.
+	// Entry.Serve selects the representation which best matches the Accept, Accept-Language
+	// and Match request header fields, and sends the URI of a selected variant as Content-Location.
+	// A variant may also be added to the cache under its own URI so that it can be requested directly.
+	Variants []*Entry
+
+	// Request header fields which must have one of the given values, compared case-insensitively, for this variant to be selected.
+	// Among otherwise equally acceptable variants, the one with the most matching fields is preferred.
+	Match http.Header
+
+	// How often the content of this entry is likely to change, announced in the sitemap.
+	// Valid values are "always", "hourly", "daily", "weekly", "monthly", "yearly" and "never".
+	ChangeFreq string
+
+	// The priority of this entry relative to other entries of the same site, from 0.1 to 1.0, announced in the sitemap.
+	// 0 omits the priority, which then defaults to 0.5.
+	Priority float64
+
+	// The URIs of translations of this entry by language tag, announced in the sitemap.
+	// Variants with a Language and their own URI are announced as well.
+	Alternates map[string]string
+
+	// The request methods with which this entry may be requested, nil for GET and HEAD.
+	// HEAD is allowed whenever GET is.
+	// Requests with OPTIONS are answered with the allowed methods in the Allow header field,
+	// requests with other methods with 405 Method Not Allowed.
+	Methods []string
+
+	// NoIndex excludes this entry from the sitemap, and asks search engines not to index it with the X-Robots-Tag header field.
+	NoIndex bool
+
+	// Redirect, if not empty, makes this entry a redirect to the given target, see NewRedirect.
+	// Redirects answer requests with any method, and are excluded from the sitemap.
+	Redirect string
+
+	// The status code of a Redirect, like http.StatusFound, or 0 for http.StatusMovedPermanently.
+	RedirectStatus int
+
+	// The freshness of an entry stored by a Proxy or Memoize, nil for other entries.
+	freshness *freshness
+
+	// The logical name of a fingerprinted entry, empty for other entries.
+	asset string
+
+	// The SHA-256 digests of the representations by content coding.
+	digests map[string][]byte
+
+	// The number of times this entry was served, shared by copies of this entry.
+	hits *int64
+
+	// The body of a file-backed entry, nil for entries with their body in memory, see NewFileEntry.
+	file *fileBody
 }
 
 // Cache is a HTTP Cache.
+// A Cache is safe for concurrent use by multiple goroutines.
+// Entries must not be modified after they have been added to a cache.
+// To update an entry, add a new entry for the same URI.
+// The zero value is an empty cache ready to use.
 type Cache struct {
-	Cache map[string]*Entry
+	metrics metrics
+	mutex   sync.RWMutex
+	entries map[string]*Entry
+
+	// The directories loaded with LoadDir, with their options.
+	dirs map[string]LoadOptions
+
+	// The limits of this cache and their eviction policy.
+	limits Limits
+
+	// Serializes calls to limits.Policy, which is also called by readers.
+	policyMutex sync.Mutex
+
+	// The number of bytes used by the bodies of all entries.
+	bytes int
+
+	// The content type rules, nil for DefaultContentTypeRules.
+	contentTypeRules []ContentTypeRule
+
+	// The default cache control policy and its overrides by URI.
+	cacheControl      CacheControl
+	cacheControlRules []CacheControlRule
+
+	// Whether responses carry integrity digests.
+	digests bool
+
+	// The rewrite rules applied by Handler.
+	rewriteRules []RewriteRule
+
+	// The entries served for error responses by status code.
+	errorEntries map[int]*Entry
+
+	// The recorder which receives the events of this cache, if any.
+	recorder Recorder
+
+	// The logical names of fingerprinted entries mapped to their URIs.
+	manifest map[string]string
+
+	// Incremented whenever an entry is added or removed, so that derived data like sitemaps can tell whether it is outdated.
+	generation uint64
+}
+
+// New creates a new, empty cache.
+func New() *Cache {
+	return &Cache{entries: make(map[string]*Entry)}
 }
 
 // GlobalCache is the global (default) cache.
-var GlobalCache = &Cache{Cache: make(map[string]*Entry)}
+var GlobalCache = New()
 
 func CacheHandlerFunc(fallback http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		relativePath := r.RequestURI[1:len(r.URL.Path)]
-		switch relativePath {
-		case "":
-			fallback.ServeHTTP(w, r)
-		default:
-			ServeCacheEntry(w, r, relativePath)
-		}
+	if fallback == nil {
+		return GlobalCache.CacheHandler(nil)
 	}
+	return GlobalCache.CacheHandler(fallback)
 }
 
 func CacheHandler(fallback http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		relativePath := r.RequestURI[1:len(r.URL.Path)]
-		switch relativePath {
-		case "":
-			fallback.ServeHTTP(w, r)
-		default:
-			ServeCacheEntry(w, r, relativePath)
-		}
-	}
+	return GlobalCache.CacheHandler(fallback)
+}
+
+// CacheHandler returns a handler that serves the entries of this cache, with DefaultIndexFile for directories,
+// and delegates requests for the root to fallback if the cache has no entry for it.
+// See Handler for details.
+func (c *Cache) CacheHandler(fallback http.Handler) http.HandlerFunc {
+	return c.Handler(HandlerOptions{IndexFile: DefaultIndexFile, Fallback: fallback}).ServeHTTP
 }
 
 func ServeCacheEntry(w http.ResponseWriter, r *http.Request, id string) {
-	if cacheEntry, ok := GlobalCache.Cache[id]; !ok {
-		http.NotFoundHandler().ServeHTTP(w, r)
+	GlobalCache.ServeCacheEntry(w, r, id)
+}
+
+// ServeCacheEntry serves the entry with the given id, or 404 Not Found if there is no such entry, see ServeError.
+func (c *Cache) ServeCacheEntry(w http.ResponseWriter, r *http.Request, id string) {
+	if cacheEntry, ok := c.Get(id); !ok {
+		c.recordMiss(id)
+		c.ServeError(w, r, http.StatusNotFound)
 	} else {
-		cacheEntry.Serve(w, r)
+		cacheEntry.serve(w, r, c, "", true)
 	}
 }
 
+// Serve serves this entry.
+// The representation is chosen among this entry and its Variants, and according to the Accept-Encoding of the client.
+// Byte range requests are always served from the uncompressed Body,
+// so that the ranges refer to the same bytes regardless of the Accept-Encoding of the client.
+// Content types are rewritten according to DefaultContentTypeRules.
+// Only requests with the Methods of this entry are served, see Methods.
 func (e *Entry) Serve(w http.ResponseWriter, r *http.Request) {
-	contentType := e.ContentType
-	contentType = fixContentType(r, contentType)
-	w.Header().Add(header.ContentType, contentType)
-	if e.ETag == "" {
-		i := md5.Sum(e.Body)
-		e.ETag = `"` + hex.EncodeToString(i[:]) + `"`
+	e.serve(w, r, nil, "", true)
+}
+
+// serve serves this entry with the settings of c, or the default settings if c is nil.
+// The URIs of entries are served under prefix, see HandlerOptions.Prefix.
+// If hit is false, the response is not counted as a hit of the cache, as for error entries and entries which were just fetched.
+func (e *Entry) serve(w http.ResponseWriter, r *http.Request, c *Cache, prefix string, hit bool) {
+	if e.Redirect != "" {
+		e.serveRedirect(w, r, c, hit)
+		return
+	}
+	if !e.allowsMethod(w, r) {
+		return
+	}
+	if hit {
+		if e.hits != nil {
+			atomic.AddInt64(e.hits, 1)
+		}
+		c.recordHit(e)
+	}
+	selected, vary := e.negotiate(r)
+	if selected != e && selected.URI != "" && selected.URI != e.URI {
+		w.Header().Set(header.ContentLocation, prefixedPath(prefix, selected.URI))
+	}
+	if e.NoIndex || selected.NoIndex {
+		w.Header().Set(xRobotsTag, "noindex")
+	}
+	selected.serveRepresentation(w, r, c, vary, hit)
+}
+
+// serveRepresentation serves this entry as the representation selected by negotiate, with the settings of c.
+// The names of the request header fields which influenced the selection are passed in vary.
+// The response is recorded in the metrics of c only if hit is true.
+func (e *Entry) serveRepresentation(w http.ResponseWriter, r *http.Request, c *Cache, vary []string, hit bool) {
+	for key, values := range e.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	contentType, contentTypeVary := rewriteContentType(c.getContentTypeRules(), r, e.ContentType)
+	for _, name := range contentTypeVary {
+		vary = appendOnce(vary, name)
+	}
+	if contentType != "" {
+		w.Header().Add(header.ContentType, contentType)
+	}
+	baseETag := e.ETag
+	if baseETag == "" {
+		baseETag = computeETag(e.Body)
+	}
+	w.Header().Add(header.AcceptRanges, Bytes)
+	w.Header().Add(header.Vary, strings.Join(append(vary, header.AcceptEncoding), ", "))
+	if e.Language != "" {
+		w.Header().Set(header.ContentLanguage, e.Language)
 	}
-	w.Header().Add(header.ETag, e.ETag)
 	if e.LastModified != nil {
 		w.Header().Add(header.LastModified, e.LastModified.Format(http.TimeFormat))
 	}
-	if e.MaxAge != 0 {
-		w.Header().Add(header.Expires, time.Now().Add(e.MaxAge).Format(http.TimeFormat))
-		w.Header().Add(header.CacheControl, fmt.Sprintf("max-age=%d", int(e.MaxAge.Seconds())))
+	e.writeCacheControl(w, c)
+	var ranges []byteRange
+	var rangeErr error
+	if rangeHeader := r.Header.Get(header.Range); rangeHeader != "" && r.Method == http.MethodGet && checkIfRange(r, baseETag, e.LastModified) {
+		ranges, rangeErr = parseRange(rangeHeader, e.bodySize())
 	}
-	if isGzip(r) {
-		w.Header().Add(header.ContentEncoding, Gzip)
-		_, _ = w.Write(e.GzipBody)
-	} else {
-		_, _ = w.Write(e.Body)
+	// A Range header field which is ignored makes the request one for the full representation.
+	isRange := ranges != nil || rangeErr != nil
+	enc := encoding{Identity, e.Body}
+	if !isRange {
+		var ok bool
+		if enc, ok = negotiateEncoding(r, e.encodings()); !ok {
+			if _, isError := w.(*statusWriter); !isError {
+				w.Header().Del(header.ContentType)
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			// The status of an error response does not depend on the Accept-Encoding, so it falls back to identity.
+			enc = encoding{Identity, e.Body}
+		}
+	}
+	etag := representationETag(baseETag, enc.coding)
+	w.Header().Add(header.ETag, etag)
+	switch checkPreconditions(r, etag, e.LastModified) {
+	case http.StatusNotModified:
+		if hit {
+			c.recordNotModified(e)
+		}
+		writeNotModified(w)
+		return
+	case http.StatusPreconditionFailed:
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	if rangeErr != nil {
+		writeRangeNotSatisfiable(w, e.bodySize())
+		return
+	}
+	digests := c.sendsDigests()
+	body, err := e.open(enc)
+	if err != nil {
+		w.Header().Del(header.ContentType)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer body.close()
+	if ranges != nil {
+		if digests {
+			e.writeDigests(w, Identity, false)
+		}
+		serveRanges(w, body, contentType, ranges)
+		if hit {
+			c.recordServed(e, Identity, rangesLength(ranges))
+		}
+		return
+	}
+	if enc.coding != Identity {
+		w.Header().Add(header.ContentEncoding, enc.coding)
+	}
+	if digests {
+		e.writeDigests(w, enc.coding, true)
+	}
+	w.Header().Set(header.ContentLength, strconv.FormatInt(body.size, 10))
+	if r.Method != http.MethodHead {
+		body.mustWrite(w, 0, body.size)
+		if hit {
+			c.recordServed(e, enc.coding, int(body.size))
+		}
 	}
 }
 
-func fixContentType(request *http.Request, s string) string {
-	if s != mimetype.ApplicationXhtmlXml {
-		return s
+// allowedMethods returns the request methods with which this entry may be requested, except OPTIONS.
+func (e *Entry) allowedMethods() []string {
+	if e.Methods == nil {
+		return []string{http.MethodGet, http.MethodHead}
 	}
-	userAgent := request.Header.Get("User-Agent")
-	if strings.Contains(userAgent, "Twitter") || strings.Contains(userAgent, "LinkedIn") {
-		return "text/html"
+	methods := make([]string, 0, len(e.Methods)+1)
+	for _, method := range e.Methods {
+		if method != http.MethodOptions {
+			methods = appendOnce(methods, method)
+		}
+		if method == http.MethodGet {
+			methods = appendOnce(methods, http.MethodHead)
+		}
 	}
-	return s
+	return methods
 }
 
-func isGzip(r *http.Request) bool {
-	acceptEncoding := r.Header.Get(header.AcceptEncoding)
-	encodings := strings.Split(acceptEncoding, ",")
-	for _, encoding := range encodings {
-		if strings.TrimSpace(encoding) == Gzip {
+// allowsMethod returns true if this entry may be served for the method of r.
+// Otherwise, it answers OPTIONS requests with the allowed methods, and other requests with 405 Method Not Allowed.
+func (e *Entry) allowsMethod(w http.ResponseWriter, r *http.Request) bool {
+	methods := e.allowedMethods()
+	for _, method := range methods {
+		if r.Method == method {
 			return true
 		}
 	}
+	w.Header().Set(header.Allow, strings.Join(append(methods, http.MethodOptions), ", "))
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
 	return false
 }
 
@@ -142,14 +407,14 @@ func (c *Cache) LoadCacheFile(filename string, uri string, contentType string, m
 		return err
 	}
 	modTime := fileStat.ModTime().UTC()
-	c.Cache[uri] = &Entry{
+	c.Add(&Entry{
 		URI:          uri,
 		Body:         body,
-		GzipBody:     compressGzip(body),
 		ContentType:  contentType,
 		LastModified: &modTime,
 		MaxAge:       maxAge,
-	}
+		Filename:     filename,
+	})
 	return nil
 }
 
@@ -158,8 +423,15 @@ func LoadCacheFile(filename string, uri string, contentType string, maxAge time.
 }
 
 func compressGzip(data []byte) []byte {
+	return compressGzipLevel(data, gzip.DefaultCompression)
+}
+
+func compressGzipLevel(data []byte, level int) []byte {
 	var b bytes.Buffer
-	gz := gzip.NewWriter(&b)
+	gz, err := gzip.NewWriterLevel(&b, level)
+	if err != nil {
+		panic(err)
+	}
 	if _, err := gz.Write(data); err != nil {
 		panic(err)
 	}
@@ -172,47 +444,249 @@ func compressGzip(data []byte) []byte {
 	return b.Bytes()
 }
 
+func computeETag(body []byte) string {
+	i := md5.Sum(body)
+	return `"` + hex.EncodeToString(i[:]) + `"`
+}
+
 func (c *Cache) Size() (entries int, memory int) {
-	for _, entry := range c.Cache {
-		entries++
-		memory += len(entry.Body) + len(entry.GzipBody)
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.entries), c.bytes
+}
+
+// size returns the number of bytes used by the bodies of this entry and its variants.
+func (e *Entry) size() int {
+	size := len(e.Body) + len(e.GzipBody) + len(e.BrotliBody) + len(e.ZstdBody)
+	for _, variant := range e.Variants {
+		size += variant.size()
 	}
-	return entries, memory
+	return size
 }
 
 func Size() (entries int, memory int) {
 	return GlobalCache.Size()
 }
 
+// Add adds an entry to this cache, replacing any existing entry with the same URI.
+// Missing compressed bodies, the ETag and the integrity values are computed before the entry becomes visible.
 func (c *Cache) Add(entry *Entry) {
-	if entry.GzipBody == nil {
-		entry.GzipBody = compressGzip(entry.Body)
-	}
-	c.Cache[entry.URI] = entry
+	entry.prepare()
+	c.mutex.Lock()
+	c.put(entry)
+	evicted := c.evict()
+	c.mutex.Unlock()
+	c.notifyEvicted(evicted)
 }
 
 func Add(entry *Entry) {
 	GlobalCache.Add(entry)
 }
 
-func (c *Cache) Sitemap(r *http.Request) string {
-	sitemap := `<?xml version="1.0" encoding="UTF-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.sitemaps.org/schemas/sitemap/0.9 http://www.sitemaps.org/schemas/sitemap/0.9/sitemap.xsd">
-`
-	for key, entry := range c.Cache {
-		switch entry.ContentType {
-		case mimetype.ApplicationXhtmlXml, mimetype.TextHtml:
-			if entry.LastModified != nil {
-				sitemap += fmt.Sprintf("<url><loc>https://%s%s</loc><lastmod>%s</lastmod></url>\n", r.Host, key, entry.LastModified.Format(time.RFC3339Nano))
-			} else {
-				sitemap += fmt.Sprintf("<url><loc>https://%s%s</loc></url>\n", r.Host, entry.URI)
-			}
+// prepare computes the missing compressed bodies, ETag and integrity values of this entry and its variants.
+func (e *Entry) prepare() {
+	for _, variant := range e.Variants {
+		variant.prepare()
+	}
+	if e.file == nil {
+		if e.GzipBody == nil {
+			e.GzipBody = compressGzip(e.Body)
+		}
+		if e.BrotliBody == nil {
+			e.BrotliBody = compressBrotli(e.Body)
+		}
+		if e.ZstdBody == nil {
+			e.ZstdBody = compressZstd(e.Body)
+		}
+	}
+	e.prepareIdentity()
+}
+
+// prepareIdentity computes the missing ETag and integrity values of this entry without compressing it.
+func (e *Entry) prepareIdentity() {
+	if e.ETag == "" {
+		e.ETag = computeETag(e.Body)
+	}
+	if e.Integrity == nil {
+		e.Integrity = computeIntegrity(e.Body)
+	}
+	if e.digests == nil {
+		e.digests = e.computeDigests()
+	}
+	if e.hits == nil {
+		e.hits = new(int64)
+	}
+}
+
+// Get returns the entry for the given URI.
+// Get counts as an access for the eviction policy.
+func (c *Cache) Get(uri string) (*Entry, bool) {
+	c.mutex.RLock()
+	expired := c.hasExpired()
+	entry, ok := c.entries[uri]
+	if ok && !expired {
+		c.accessed(entry)
+	}
+	c.mutex.RUnlock()
+	if !expired {
+		return entry, ok
+	}
+	c.mutex.Lock()
+	evicted := c.evict()
+	entry, ok = c.entries[uri]
+	if ok {
+		c.accessed(entry)
+	}
+	c.mutex.Unlock()
+	c.notifyEvicted(evicted)
+	return entry, ok
+}
+
+func Get(uri string) (*Entry, bool) {
+	return GlobalCache.Get(uri)
+}
+
+// Remove removes the entry for the given URI, returning true if there was such an entry.
+func (c *Cache) Remove(uri string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.delete(uri)
+	return ok
+}
+
+func Remove(uri string) bool {
+	return GlobalCache.Remove(uri)
+}
+
+// RemovePrefix removes all entries whose URI starts with prefix, returning their URIs.
+// An empty prefix removes all entries.
+func (c *Cache) RemovePrefix(prefix string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var removed []string
+	for _, entry := range sortedEntries(c.entries) {
+		if strings.HasPrefix(entry.URI, prefix) {
+			c.delete(entry.URI)
+			removed = append(removed, entry.URI)
+		}
+	}
+	return removed
+}
+
+func RemovePrefix(prefix string) []string {
+	return GlobalCache.RemovePrefix(prefix)
+}
+
+// Replace atomically replaces all entries of this cache with the given entries.
+// The entries are prepared before the swap, so requests are served from the old entries until all new entries are ready.
+// This is intended for deployments where a whole site is replaced at once.
+func (c *Cache) Replace(entries ...*Entry) {
+	for _, entry := range entries {
+		entry.prepare()
+	}
+	c.mutex.Lock()
+	for uri := range c.entries {
+		c.delete(uri)
+	}
+	for _, entry := range entries {
+		c.put(entry)
+	}
+	evicted := c.evict()
+	c.mutex.Unlock()
+	c.notifyEvicted(evicted)
+}
+
+func Replace(entries ...*Entry) {
+	GlobalCache.Replace(entries...)
+}
+
+// Entries returns a snapshot of the entries of this cache, sorted by URI.
+func (c *Cache) Entries() []*Entry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return sortedEntries(c.entries)
+}
+
+// sortedEntries returns the entries of the given map, sorted by URI.
+func sortedEntries(m map[string]*Entry) []*Entry {
+	entries := make([]*Entry, 0, len(m))
+	for _, entry := range m {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URI < entries[j].URI })
+	return entries
+}
+
+// peek returns the entry for the given URI without counting as an access.
+func (c *Cache) peek(uri string) (*Entry, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry, ok := c.entries[uri]
+	return entry, ok
+}
+
+// put stores entry, replacing any existing entry with the same URI.
+// c.mutex must be held for writing.
+func (c *Cache) put(entry *Entry) {
+	c.delete(entry.URI)
+	if c.entries == nil {
+		c.entries = make(map[string]*Entry)
+	}
+	c.entries[entry.URI] = entry
+	c.bytes += entry.size()
+	c.generation++
+	if c.limits.Policy != nil && !entry.Pinned {
+		c.policyMutex.Lock()
+		c.limits.Policy.Added(entry)
+		c.policyMutex.Unlock()
+	}
+}
+
+// delete removes the entry for the given URI, returning the removed entry.
+// c.mutex must be held for writing.
+func (c *Cache) delete(uri string) (*Entry, bool) {
+	entry, ok := c.entries[uri]
+	if !ok {
+		return nil, false
+	}
+	delete(c.entries, uri)
+	c.bytes -= entry.size()
+	c.generation++
+	if entry.asset != "" && c.manifest[entry.asset] == uri {
+		delete(c.manifest, entry.asset)
+	}
+	if c.limits.Policy != nil && !entry.Pinned {
+		c.policyMutex.Lock()
+		c.limits.Policy.Removed(entry)
+		c.policyMutex.Unlock()
+	}
+	return entry, true
+}
+
+// accessed informs the eviction policy about an access of entry.
+// c.mutex must be held.
+func (c *Cache) accessed(entry *Entry) {
+	if c.limits.Policy != nil && !entry.Pinned {
+		c.policyMutex.Lock()
+		c.limits.Policy.Accessed(entry)
+		c.policyMutex.Unlock()
+	}
+}
+
+func Entries() []*Entry {
+	return GlobalCache.Entries()
+}
+
+// Range calls f for each entry of this cache, sorted by URI, until f returns false.
+// Range iterates over a snapshot, so f may modify the cache.
+func (c *Cache) Range(f func(entry *Entry) bool) {
+	for _, entry := range c.Entries() {
+		if !f(entry) {
+			return
 		}
 	}
-	sitemap += `</urlset>`
-	return sitemap
 }
 
-func Sitemap(r *http.Request) string {
-	return GlobalCache.Sitemap(r)
-}
\ No newline at end of file
+func Range(f func(entry *Entry) bool) {
+	GlobalCache.Range(f)
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestAddGetRemove(t *testing.T) {
+	c := New()
+	c.Add(&Entry{URI: "a", Body: []byte("a")})
+	entry, ok := c.Get("a")
+	if !ok || string(entry.Body) != "a" || entry.ETag == "" || entry.GzipBody == nil {
+		t.Fatalf("got %v, %v, want a prepared entry", entry, ok)
+	}
+	c.Add(&Entry{URI: "a", Body: []byte("b")})
+	if entry, _ := c.Get("a"); string(entry.Body) != "b" {
+		t.Errorf("got body %q after replacing, want \"b\"", entry.Body)
+	}
+	if !c.Remove("a") || c.Remove("a") {
+		t.Error("Remove should report true only for an existing entry")
+	}
+	if entries, memory := c.Size(); entries != 0 || memory != 0 {
+		t.Errorf("got size %d, %d for an empty cache", entries, memory)
+	}
+}
+
+func TestRemovePrefix(t *testing.T) {
+	c := New()
+	for _, uri := range []string{"docs/a", "docs/b", "img/c"} {
+		c.Add(&Entry{URI: uri})
+	}
+	if removed := c.RemovePrefix("docs/"); fmt.Sprint(removed) != "[docs/a docs/b]" {
+		t.Errorf("got %v", removed)
+	}
+	if entries, _ := c.Size(); entries != 1 {
+		t.Errorf("got %d entries, want 1", entries)
+	}
+}
+
+// TestReplaceIsAtomic checks that concurrent readers never see a mix of the old and the new entries.
+func TestReplaceIsAtomic(t *testing.T) {
+	c := New()
+	generation := func(body string) []*Entry {
+		entries := make([]*Entry, 10)
+		for i := range entries {
+			entries[i] = &Entry{URI: fmt.Sprintf("e%d", i), Body: []byte(body)}
+		}
+		return entries
+	}
+	c.Replace(generation("old")...)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				entries := c.Entries()
+				for _, entry := range entries {
+					if string(entry.Body) != string(entries[0].Body) {
+						t.Errorf("got a mix of %q and %q", entry.Body, entries[0].Body)
+						return
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		c.Replace(generation(fmt.Sprint(i))...)
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// TestConcurrentUse changes and serves the entries of a cache concurrently, and is meant to be run with -race.
+func TestConcurrentUse(t *testing.T) {
+	c := New()
+	handler := c.CacheHandler(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				uri := fmt.Sprintf("f%d", j%5)
+				switch (i + j) % 5 {
+				case 0:
+					c.Add(&Entry{URI: uri, Body: []byte(uri)})
+				case 1:
+					c.Remove(uri)
+				case 2:
+					c.Replace(&Entry{URI: uri, Body: []byte("replaced")})
+				case 3:
+					c.Range(func(entry *Entry) bool { return entry.URI != "f3" })
+				default:
+					w := doRequest(handler, http.MethodGet, "/"+uri)
+					if w.Code != http.StatusOK && w.Code != http.StatusNotFound {
+						t.Errorf("got status %d", w.Code)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	entries, memory := c.Size()
+	sum := 0
+	for _, entry := range c.Entries() {
+		sum += entry.size()
+	}
+	if entries != len(c.Entries()) || memory != sum {
+		t.Errorf("got size %d, %d, want %d, %d", entries, memory, len(c.Entries()), sum)
+	}
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The header field for directives to surrogates like CDNs, as per the W3C Edge Architecture Specification.
+const surrogateControl = "Surrogate-Control"
+
+// CacheControl is a policy for caching a response, sent as Cache-Control header field as per RFC 9111 section 5.2.2.
+// Durations of 0 omit the corresponding directive.
+type CacheControl struct {
+
+	// Public allows shared caches to store the response even if it would otherwise not be cacheable.
+	Public bool
+
+	// Private forbids shared caches to store the response.
+	Private bool
+
+	// NoCache requires caches to revalidate the response before every reuse.
+	NoCache bool
+
+	// NoStore forbids caches to store the response.
+	NoStore bool
+
+	// NoTransform forbids intermediaries to transform the response.
+	NoTransform bool
+
+	// MustRevalidate forbids caches to reuse the response without revalidation once it is stale.
+	MustRevalidate bool
+
+	// ProxyRevalidate is like MustRevalidate, but for shared caches only.
+	ProxyRevalidate bool
+
+	// Immutable tells clients that the response will not change while it is fresh, as per RFC 8246.
+	Immutable bool
+
+	// MaxAge is the time for which the response is fresh.
+	// If 0, the MaxAge of the entry is used.
+	MaxAge time.Duration
+
+	// SMaxAge is the time for which the response is fresh in shared caches.
+	SMaxAge time.Duration
+
+	// StaleWhileRevalidate is the time for which a stale response may be reused while it is revalidated in the background, as per RFC 5861.
+	StaleWhileRevalidate time.Duration
+
+	// StaleIfError is the time for which a stale response may be reused if revalidation fails, as per RFC 5861.
+	StaleIfError time.Duration
+
+	// CDN, if not nil, is sent as CDN-Cache-Control header field as per RFC 9213, for CDNs only.
+	CDN *CacheControl
+
+	// Surrogate, if not nil, is sent as Surrogate-Control header field, for surrogates which do not support CDN-Cache-Control.
+	// Of its directives, surrogates support MaxAge and NoStore.
+	Surrogate *CacheControl
+}
+
+// CacheControlRule sets the cache control policy of all entries whose URI matches Pattern.
+// Example: hashed assets, which never change, can be cached forever:
+//
+//	CacheControlRule{Pattern: regexp.MustCompile(`\.[0-9a-f]{8,}\.(css|js)$`), CacheControl: CacheControl{Public: true, Immutable: true, MaxAge: 365 * 24 * time.Hour}}
+type CacheControlRule struct {
+	Pattern      *regexp.Regexp
+	CacheControl CacheControl
+}
+
+// SetCacheControl sets the cache control policies of this cache.
+// The policy of an entry is its own CacheControl, if not nil,
+// otherwise the CacheControl of the first rule whose Pattern matches its URI, otherwise defaults.
+// Entries whose Header contains Cache-Control, like those stored by a Proxy, are sent with that header field instead.
+func (c *Cache) SetCacheControl(defaults CacheControl, rules []CacheControlRule) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cacheControl = defaults
+	c.cacheControlRules = rules
+}
+
+func SetCacheControl(defaults CacheControl, rules []CacheControlRule) {
+	GlobalCache.SetCacheControl(defaults, rules)
+}
+
+// getCacheControl returns the cache control policy for entry, with the MaxAge of entry if the policy has none.
+// If c is nil, it is the CacheControl of entry, or none.
+func (c *Cache) getCacheControl(entry *Entry) CacheControl {
+	policy := c.findCacheControl(entry)
+	if policy.MaxAge == 0 {
+		policy.MaxAge = entry.MaxAge
+	}
+	return policy
+}
+
+// findCacheControl returns the cache control policy for entry as configured, see SetCacheControl.
+func (c *Cache) findCacheControl(entry *Entry) CacheControl {
+	if entry.CacheControl != nil {
+		return *entry.CacheControl
+	}
+	if c == nil {
+		return CacheControl{}
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for _, rule := range c.cacheControlRules {
+		if rule.Pattern.MatchString(entry.URI) {
+			return rule.CacheControl
+		}
+	}
+	return c.cacheControl
+}
+
+// maxAge returns the max-age with which entry is served by c, from its Cache-Control header field or its policy.
+func (c *Cache) maxAge(entry *Entry) time.Duration {
+	if len(entry.Header.Values(header.CacheControl)) > 0 {
+		maxAge, _ := directiveSeconds(parseCacheControl(entry.Header), "max-age")
+		return maxAge
+	}
+	return c.getCacheControl(entry).MaxAge
+}
+
+// writeCacheControl sets the Cache-Control, CDN-Cache-Control, Surrogate-Control and Expires header fields of the response for this entry.
+func (e *Entry) writeCacheControl(w http.ResponseWriter, c *Cache) {
+	if len(e.Header.Values(header.CacheControl)) > 0 {
+		return
+	}
+	policy := c.getCacheControl(e)
+	if value := policy.String(); value != "" {
+		w.Header().Set(header.CacheControl, value)
+	}
+	if policy.CDN != nil {
+		w.Header().Set(header.CDNCacheControl, policy.CDN.String())
+	}
+	if policy.Surrogate != nil {
+		w.Header().Set(surrogateControl, policy.Surrogate.String())
+	}
+	if policy.MaxAge > 0 && !policy.NoCache && !policy.NoStore {
+		// For HTTP/1.0 caches, which do not understand max-age.
+		w.Header().Set(header.Expires, time.Now().Add(policy.MaxAge).Format(http.TimeFormat))
+	}
+}
+
+// String returns the value of the Cache-Control header field for this policy.
+func (cc *CacheControl) String() string {
+	var directives []string
+	flag := func(set bool, directive string) {
+		if set {
+			directives = append(directives, directive)
+		}
+	}
+	seconds := func(d time.Duration, directive string) {
+		if d > 0 {
+			directives = append(directives, directive+"="+strconv.FormatInt(int64(d/time.Second), 10))
+		}
+	}
+	flag(cc.Public, "public")
+	flag(cc.Private, "private")
+	flag(cc.NoCache, "no-cache")
+	flag(cc.NoStore, "no-store")
+	flag(cc.NoTransform, "no-transform")
+	seconds(cc.MaxAge, "max-age")
+	seconds(cc.SMaxAge, "s-maxage")
+	flag(cc.MustRevalidate, "must-revalidate")
+	flag(cc.ProxyRevalidate, "proxy-revalidate")
+	flag(cc.Immutable, "immutable")
+	seconds(cc.StaleWhileRevalidate, "stale-while-revalidate")
+	seconds(cc.StaleIfError, "stale-if-error")
+	return strings.Join(directives, ", ")
+}
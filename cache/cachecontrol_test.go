@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCacheControlString(t *testing.T) {
+	tests := []struct {
+		cc   CacheControl
+		want string
+	}{
+		{CacheControl{}, ""},
+		{CacheControl{NoStore: true}, "no-store"},
+		{CacheControl{Public: true, MaxAge: time.Hour, Immutable: true}, "public, max-age=3600, immutable"},
+		{CacheControl{Private: true, NoCache: true, MustRevalidate: true}, "private, no-cache, must-revalidate"},
+		{CacheControl{MaxAge: 1500 * time.Millisecond, SMaxAge: time.Minute, StaleWhileRevalidate: time.Minute, StaleIfError: time.Hour}, "max-age=1, s-maxage=60, stale-while-revalidate=60, stale-if-error=3600"},
+		{CacheControl{NoTransform: true, ProxyRevalidate: true}, "no-transform, proxy-revalidate"},
+	}
+	for _, test := range tests {
+		if got := test.cc.String(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestSetCacheControl(t *testing.T) {
+	c := New()
+	c.Add(&Entry{URI: "a.css", Body: []byte("x"), ContentType: "text/css", MaxAge: time.Minute})
+	c.Add(&Entry{URI: "app.0123456789.js", Body: []byte("x"), ContentType: "text/javascript"})
+	c.Add(&Entry{URI: "own", Body: []byte("x"), ContentType: "text/plain", CacheControl: &CacheControl{Private: true, NoCache: true}})
+	c.Add(&Entry{URI: "header", Body: []byte("x"), ContentType: "text/plain", Header: http.Header{header.CacheControl: {"max-age=5"}}})
+	c.SetCacheControl(
+		CacheControl{Public: true, StaleWhileRevalidate: time.Minute, CDN: &CacheControl{MaxAge: time.Hour}, Surrogate: &CacheControl{MaxAge: time.Hour}},
+		[]CacheControlRule{{Pattern: regexp.MustCompile(`\.[0-9a-f]{8,}\.js$`), CacheControl: CacheControl{Public: true, Immutable: true, MaxAge: 365 * 24 * time.Hour}}},
+	)
+	tests := []struct {
+		path         string
+		cacheControl string
+		cdn          string
+		surrogate    string
+		expires      bool
+	}{
+		{"/a.css", "public, max-age=60, stale-while-revalidate=60", "max-age=3600", "max-age=3600", true},
+		{"/app.0123456789.js", "public, max-age=31536000, immutable", "", "", true},
+		{"/own", "private, no-cache", "", "", false},
+		{"/header", "max-age=5", "", "", false},
+	}
+	for _, test := range tests {
+		w := doRequest(c.CacheHandler(nil), http.MethodGet, test.path)
+		h := w.Header()
+		if h.Get(header.CacheControl) != test.cacheControl || h.Get(header.CDNCacheControl) != test.cdn || h.Get(surrogateControl) != test.surrogate {
+			t.Errorf("%s: got %q, %q, %q", test.path, h.Get(header.CacheControl), h.Get(header.CDNCacheControl), h.Get(surrogateControl))
+		}
+		if expires := h.Get(header.Expires) != ""; expires != test.expires {
+			t.Errorf("%s: got Expires %q", test.path, h.Get(header.Expires))
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"bytes"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+)
+
+// capture is a http.ResponseWriter which records a response in memory.
+// A capture created by newRelay relays responses which are not to be stored to a client instead.
+type capture struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+
+	// The client to which the response is relayed, or nil if it is always recorded.
+	client http.ResponseWriter
+
+	// keep returns true if the response with the given status and header fields is to be recorded.
+	keep func(status int, h http.Header) bool
+
+	// The maximum size of a recorded body, or 0 for no limit.
+	maxBytes int
+
+	// True if the response is relayed to the client.
+	relayed bool
+}
+
+func newCapture() *capture {
+	return &capture{header: make(http.Header)}
+}
+
+// newRelay returns a capture which records the response only if keep returns true for its status and header fields,
+// and if its body does not exceed maxBytes, unless maxBytes is 0.
+// Otherwise, the response is relayed to client as it is written, with the part of the body recorded so far.
+func newRelay(client http.ResponseWriter, keep func(status int, h http.Header) bool, maxBytes int) *capture {
+	return &capture{header: make(http.Header), client: client, keep: keep, maxBytes: maxBytes}
+}
+
+func (c *capture) Header() http.Header {
+	return c.header
+}
+
+func (c *capture) WriteHeader(status int) {
+	if c.status != 0 {
+		return
+	}
+	c.status = status
+	if c.client != nil && !c.keep(status, c.header) {
+		c.relay()
+	}
+}
+
+func (c *capture) Write(b []byte) (int, error) {
+	c.WriteHeader(http.StatusOK)
+	if !c.relayed && c.client != nil && c.maxBytes > 0 && c.body.Len()+len(b) > c.maxBytes {
+		c.relay()
+	}
+	if c.relayed {
+		return c.client.Write(b)
+	}
+	return c.body.Write(b)
+}
+
+// Flush flushes the client if the response is relayed, so that streamed responses reach it without delay.
+func (c *capture) Flush() {
+	if flusher, ok := c.client.(http.Flusher); ok && c.relayed {
+		flusher.Flush()
+	}
+}
+
+// relay starts relaying the response to the client, beginning with the part of the body recorded so far.
+func (c *capture) relay() {
+	c.relayed = true
+	for key, values := range c.header {
+		c.client.Header()[key] = values
+	}
+	c.client.WriteHeader(c.status)
+	_, _ = c.client.Write(c.body.Bytes())
+	c.body = bytes.Buffer{}
+}
+
+// entry returns an entry for the given URI with the captured response.
+// Like net/http, it detects the content type of the body if the response has no Content-Type header field.
+func (c *capture) entry(uri string) *Entry {
+	entry := &Entry{
+		URI:         uri,
+		Body:        append([]byte(nil), c.body.Bytes()...),
+		ContentType: c.header.Get(header.ContentType),
+		ETag:        c.header.Get(header.ETag),
+		Header:      endToEndHeader(c.header),
+	}
+	if _, ok := c.header[header.ContentType]; !ok {
+		entry.ContentType = http.DetectContentType(entry.Body)
+	}
+	if lastModified, ok := parseHTTPDate(c.header.Get(header.LastModified)); ok {
+		entry.LastModified = &lastModified
+	}
+	return entry
+}
+
+// discard is a http.ResponseWriter which discards the response.
+type discard http.Header
+
+func (d discard) Header() http.Header {
+	return http.Header(d)
+}
+
+func (d discard) WriteHeader(int) {
+}
+
+func (d discard) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// writeTo relays the captured response to w.
+func (c *capture) writeTo(w http.ResponseWriter) {
+	for key, values := range c.header {
+		w.Header()[key] = values
+	}
+	c.WriteHeader(http.StatusOK)
+	w.WriteHeader(c.status)
+	_, _ = w.Write(c.body.Bytes())
+}
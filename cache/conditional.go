@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// conditionalHeaders are the request header fields which make a request conditional or partial.
+var conditionalHeaders = []string{header.IfMatch, header.IfNoneMatch, header.IfModifiedSince, header.IfUnmodifiedSince, header.IfRange, header.Range}
+
+// checkPreconditions evaluates the conditional request header fields of r against the selected representation
+// described by etag and lastModified, following the precedence rules of RFC 9110 section 13.2.2.
+// It returns http.StatusOK if the request should be processed normally,
+// http.StatusNotModified if a 304 response should be sent,
+// or http.StatusPreconditionFailed if a 412 response should be sent.
+func checkPreconditions(r *http.Request, etag string, lastModified *time.Time) int {
+	if ifMatch, ok := r.Header[header.IfMatch]; ok {
+		if !matchesETag(strings.Join(ifMatch, ","), etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if lastModified != nil {
+		if since, ok := parseHTTPDate(r.Header.Get(header.IfUnmodifiedSince)); ok && lastModified.Truncate(time.Second).After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+	if ifNoneMatch, ok := r.Header[header.IfNoneMatch]; ok {
+		if matchesETag(strings.Join(ifNoneMatch, ","), etag, false) {
+			if isSafe(r.Method) {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if lastModified != nil && isSafe(r.Method) {
+		if since, ok := parseHTTPDate(r.Header.Get(header.IfModifiedSince)); ok && !lastModified.Truncate(time.Second).After(since) {
+			return http.StatusNotModified
+		}
+	}
+	return http.StatusOK
+}
+
+// isSafe returns true if the method is GET or HEAD, the only methods for which a 304 response is defined.
+func isSafe(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// parseHTTPDate parses an HTTP-date, returning false if the value is empty or invalid.
+func parseHTTPDate(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// matchesETag returns true if the list of entity-tags in fieldValue contains "*" or an entity-tag that matches etag.
+// If strong is true, the strong comparison function is used, otherwise the weak comparison function.
+func matchesETag(fieldValue string, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range parseETags(fieldValue) {
+		if candidate == "*" {
+			return true
+		}
+		if strong {
+			if !isWeak(candidate) && !isWeak(etag) && candidate == etag {
+				return true
+			}
+		} else if opaqueTag(candidate) == opaqueTag(etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseETags splits a comma-separated list of entity-tags, as used in If-Match and If-None-Match.
+// Commas are legal within the opaque-tag, so the list cannot simply be split at commas.
+func parseETags(fieldValue string) []string {
+	var etags []string
+	for s := fieldValue; s != ""; {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			break
+		}
+		if s[0] == '*' {
+			etags = append(etags, "*")
+			s = s[1:]
+			continue
+		}
+		start := 0
+		if strings.HasPrefix(s, "W/") {
+			start = 2
+		}
+		if len(s) <= start || s[start] != '"' {
+			// Malformed entity-tag, skip to the next list element.
+			if i := strings.IndexByte(s, ','); i >= 0 {
+				s = s[i:]
+				continue
+			}
+			break
+		}
+		end := strings.IndexByte(s[start+1:], '"')
+		if end < 0 {
+			break
+		}
+		end += start + 2
+		etags = append(etags, s[:end])
+		s = s[end:]
+	}
+	return etags
+}
+
+// isWeak returns true if etag is a weak entity-tag.
+func isWeak(etag string) bool {
+	return strings.HasPrefix(etag, "W/")
+}
+
+// opaqueTag returns the opaque-tag of etag, that is, etag without the weakness indicator.
+func opaqueTag(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
+
+// writeNotModified sends a 304 Not Modified response.
+// Representation metadata other than the validator and caching header fields is removed, as per RFC 9110 section 15.4.5.
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del(header.ContentType)
+	h.Del(header.ContentLength)
+	h.Del(header.ContentEncoding)
+	w.WriteHeader(http.StatusNotModified)
+}
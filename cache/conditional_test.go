@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// doRequest serves a request with the given method and target with h.
+// The header fields of the request are given as pairs of name and value.
+func doRequest(h http.Handler, method string, target string, fields ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(fields); i += 2 {
+		r.Header.Add(fields[i], fields[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestConditionalRequests(t *testing.T) {
+	lastModified := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	before := lastModified.Add(-time.Second).Format(http.TimeFormat)
+	entry := &Entry{URI: "x", Body: []byte("hello"), ContentType: "text/plain", LastModified: &lastModified, Methods: []string{http.MethodGet, http.MethodPost}}
+	entry.prepare()
+	etag := entry.ETag
+	tests := []struct {
+		name   string
+		method string
+		fields []string
+		status int
+	}{
+		{"unconditional", http.MethodGet, nil, http.StatusOK},
+		{"If-None-Match matching", http.MethodGet, []string{header.IfNoneMatch, etag}, http.StatusNotModified},
+		{"If-None-Match weak", http.MethodGet, []string{header.IfNoneMatch, "W/" + etag}, http.StatusNotModified},
+		{"If-None-Match list", http.MethodGet, []string{header.IfNoneMatch, `"a,b", ` + etag}, http.StatusNotModified},
+		{"If-None-Match star", http.MethodGet, []string{header.IfNoneMatch, "*"}, http.StatusNotModified},
+		{"If-None-Match star unsafe", http.MethodPost, []string{header.IfNoneMatch, "*"}, http.StatusPreconditionFailed},
+		{"If-None-Match other", http.MethodGet, []string{header.IfNoneMatch, `"other"`}, http.StatusOK},
+		{"If-Match weak", http.MethodGet, []string{header.IfMatch, "W/" + etag}, http.StatusPreconditionFailed},
+		{"If-Match matching", http.MethodGet, []string{header.IfMatch, etag}, http.StatusOK},
+		{"If-Modified-Since same", http.MethodGet, []string{header.IfModifiedSince, lastModified.Format(http.TimeFormat)}, http.StatusNotModified},
+		{"If-Modified-Since before", http.MethodGet, []string{header.IfModifiedSince, before}, http.StatusOK},
+		{"If-Modified-Since invalid", http.MethodGet, []string{header.IfModifiedSince, "yesterday"}, http.StatusOK},
+		{"If-None-Match over If-Modified-Since", http.MethodGet, []string{header.IfNoneMatch, `"other"`, header.IfModifiedSince, lastModified.Format(http.TimeFormat)}, http.StatusOK},
+		{"If-Unmodified-Since before", http.MethodGet, []string{header.IfUnmodifiedSince, before}, http.StatusPreconditionFailed},
+		{"If-Match over If-Unmodified-Since", http.MethodGet, []string{header.IfMatch, etag, header.IfUnmodifiedSince, before}, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := doRequest(http.HandlerFunc(entry.Serve), test.method, "/x", test.fields...)
+			if w.Code != test.status {
+				t.Fatalf("got status %d, want %d", w.Code, test.status)
+			}
+			if w.Code == http.StatusNotModified {
+				if w.Body.Len() != 0 {
+					t.Errorf("got body %q for 304", w.Body.String())
+				}
+				if got := w.Header().Get(header.ETag); got != etag {
+					t.Errorf("got ETag %q, want %q", got, etag)
+				}
+				if got := w.Header().Get(header.ContentLength); got != "" {
+					t.Errorf("got Content-Length %q for 304", got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"net/http"
+	"regexp"
+)
+
+// ContentTypeRule rewrites the content type served to some clients.
+// Typical use is serving XHTML as HTML to crawlers which only understand HTML, like those generating link previews.
+type ContentTypeRule struct {
+
+	// The content type to rewrite, compared without parameters.
+	From string
+
+	// The content type to serve instead.
+	To string
+
+	// If not nil, the rule applies only to clients whose User-Agent matches.
+	UserAgent *regexp.Regexp
+
+	// If true, the rule applies only to clients whose Accept header field does not accept From.
+	UnlessAccepted bool
+}
+
+// DefaultContentTypeRules are the content type rules used by caches without their own rules, and by Entry.Serve.
+// They serve XHTML as HTML to link preview crawlers of social networks and messengers,
+// and to all clients which do not accept XHTML.
+var DefaultContentTypeRules = []ContentTypeRule{
+	{
+		From:      mimetype.ApplicationXhtmlXml,
+		To:        mimetype.TextHtml,
+		UserAgent: regexp.MustCompile(`Twitter|LinkedIn|Slackbot|Slack-ImgProxy|facebookexternalhit|Facebot|Discordbot|Mastodon|TelegramBot|WhatsApp|Pinterest|redditbot|SkypeUriPreview|Embedly|Iframely`),
+	},
+	{
+		From:           mimetype.ApplicationXhtmlXml,
+		To:             mimetype.TextHtml,
+		UnlessAccepted: true,
+	},
+}
+
+// SetContentTypeRules sets the content type rules of this cache.
+// The first matching rule wins.
+// If rules is nil, DefaultContentTypeRules are used, if rules is empty, content types are never rewritten.
+func (c *Cache) SetContentTypeRules(rules []ContentTypeRule) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.contentTypeRules = rules
+}
+
+func SetContentTypeRules(rules []ContentTypeRule) {
+	GlobalCache.SetContentTypeRules(rules)
+}
+
+// getContentTypeRules returns the content type rules of c, which may be nil.
+func (c *Cache) getContentTypeRules() []ContentTypeRule {
+	if c == nil {
+		return DefaultContentTypeRules
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.contentTypeRules == nil {
+		return DefaultContentTypeRules
+	}
+	return c.contentTypeRules
+}
+
+// rewriteContentType applies the first matching rule to contentType.
+// It also returns the names of the request header fields which rules for this content type depend on, for the Vary header field.
+func rewriteContentType(rules []ContentTypeRule, r *http.Request, contentType string) (string, []string) {
+	var vary []string
+	rewritten := ""
+	t := mediaType(contentType)
+	for _, rule := range rules {
+		if mediaType(rule.From) != t {
+			continue
+		}
+		if rule.UserAgent != nil {
+			vary = appendOnce(vary, header.UserAgent)
+		}
+		if rule.UnlessAccepted {
+			vary = appendOnce(vary, header.Accept)
+		}
+		if rewritten == "" && rule.matches(r) {
+			rewritten = rule.To
+		}
+	}
+	if rewritten == "" {
+		rewritten = contentType
+	}
+	return rewritten, vary
+}
+
+// matches returns true if this rule applies to the client of r.
+func (rule *ContentTypeRule) matches(r *http.Request) bool {
+	if rule.UserAgent != nil && !rule.UserAgent.MatchString(r.Header.Get(header.UserAgent)) {
+		return false
+	}
+	if rule.UnlessAccepted && mediaTypeQuality(parseQualityList(r.Header.Values(header.Accept)), rule.From) > 0 {
+		return false
+	}
+	return true
+}
+
+// appendOnce appends s to list unless list already contains s.
+func appendOnce(list []string, s string) []string {
+	for _, element := range list {
+		if element == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
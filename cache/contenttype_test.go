@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestDefaultContentTypeRules(t *testing.T) {
+	c := New()
+	c.Add(&Entry{URI: "a", Body: []byte("<html/>"), ContentType: mimetype.ApplicationXhtmlXml})
+	handler := c.CacheHandler(nil)
+	tests := []struct {
+		userAgent   string
+		accept      string
+		contentType string
+	}{
+		{"Slackbot 1.0", "", mimetype.TextHtml},
+		{"Mozilla/5.0", "", mimetype.ApplicationXhtmlXml},
+		{"Mozilla/5.0", "text/html", mimetype.TextHtml},
+		{"Mozilla/5.0", "text/html,*/*;q=0.8", mimetype.ApplicationXhtmlXml},
+	}
+	for _, test := range tests {
+		fields := []string{header.UserAgent, test.userAgent}
+		if test.accept != "" {
+			fields = append(fields, header.Accept, test.accept)
+		}
+		w := doRequest(handler, http.MethodGet, "/a", fields...)
+		if got := w.Header().Get(header.ContentType); got != test.contentType {
+			t.Errorf("%q, %q: got Content-Type %q, want %q", test.userAgent, test.accept, got, test.contentType)
+		}
+		if got := w.Header().Get(header.Vary); got != "User-Agent, Accept, Accept-Encoding" {
+			t.Errorf("got Vary %q", got)
+		}
+	}
+}
+
+func TestSetContentTypeRules(t *testing.T) {
+	c := New()
+	c.Add(&Entry{URI: "a.xhtml", Body: []byte("<html/>"), ContentType: mimetype.ApplicationXhtmlXml})
+	c.Add(&Entry{URI: "b.txt", Body: []byte("b"), ContentType: mimetype.TextPlain})
+	handler := c.CacheHandler(nil)
+
+	c.SetContentTypeRules([]ContentTypeRule{})
+	w := doRequest(handler, http.MethodGet, "/a.xhtml", header.UserAgent, "Twitterbot")
+	if w.Header().Get(header.ContentType) != mimetype.ApplicationXhtmlXml || w.Header().Get(header.Vary) != header.AcceptEncoding {
+		t.Errorf("got %v without rules", w.Header())
+	}
+
+	c.SetContentTypeRules([]ContentTypeRule{{From: mimetype.TextPlain, To: mimetype.TextMarkdown, UserAgent: regexp.MustCompile(`curl`)}})
+	if w := doRequest(handler, http.MethodGet, "/b.txt", header.UserAgent, "curl/7.0"); w.Header().Get(header.ContentType) != mimetype.TextMarkdown {
+		t.Errorf("got Content-Type %q for a matching rule", w.Header().Get(header.ContentType))
+	}
+	if w := doRequest(handler, http.MethodGet, "/a.xhtml", header.UserAgent, "curl/7.0"); w.Header().Get(header.ContentType) != mimetype.ApplicationXhtmlXml {
+		t.Errorf("got Content-Type %q, the default rules should no longer apply", w.Header().Get(header.ContentType))
+	}
+
+	c.SetContentTypeRules(nil)
+	if w := doRequest(handler, http.MethodGet, "/a.xhtml", header.UserAgent, "Twitterbot"); w.Header().Get(header.ContentType) != mimetype.TextHtml {
+		t.Errorf("got Content-Type %q, nil rules should restore the default rules", w.Header().Get(header.ContentType))
+	}
+}
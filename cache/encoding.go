@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"bytes"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// encoding is a single precompressed representation of an entry.
+type encoding struct {
+	coding string
+	body   []byte
+}
+
+// encodings returns the available representations of this entry, in order of preference.
+// The uncompressed Body is always available as Identity.
+func (e *Entry) encodings() []encoding {
+	encodings := make([]encoding, 0, 4)
+	if e.BrotliBody != nil {
+		encodings = append(encodings, encoding{Brotli, e.BrotliBody})
+	}
+	if e.ZstdBody != nil {
+		encodings = append(encodings, encoding{Zstd, e.ZstdBody})
+	}
+	if e.GzipBody != nil {
+		encodings = append(encodings, encoding{Gzip, e.GzipBody})
+	}
+	return append(encodings, encoding{Identity, e.Body})
+}
+
+// negotiateEncoding selects the representation to serve for the Accept-Encoding header field of r,
+// as per RFC 9110 section 12.5.3.
+// Among the acceptable encodings, the one with the highest qvalue is chosen, and among those the smallest.
+// It returns false if none of the encodings is acceptable.
+func negotiateEncoding(r *http.Request, encodings []encoding) (encoding, bool) {
+	values, ok := r.Header[header.AcceptEncoding]
+	if !ok {
+		// No preference stated, serve the content as is.
+		return encodings[len(encodings)-1], true
+	}
+	accepted := parseAcceptEncoding(strings.Join(values, ","))
+	var best encoding
+	bestQ := 0.0
+	for _, enc := range encodings {
+		q := accepted.qvalue(enc.coding)
+		if q > bestQ || q > 0 && q == bestQ && len(enc.body) < len(best.body) {
+			best, bestQ = enc, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// acceptEncoding maps the content codings listed in an Accept-Encoding header field to their qvalues.
+type acceptEncoding map[string]float64
+
+// parseAcceptEncoding parses the value of an Accept-Encoding header field.
+// Elements with an invalid qvalue are ignored.
+func parseAcceptEncoding(value string) acceptEncoding {
+	accepted := make(acceptEncoding)
+	for _, element := range strings.Split(value, ",") {
+		coding, q, ok := parseQualityElement(element)
+		if !ok || coding == "" {
+			continue
+		}
+		if coding == "x-gzip" {
+			coding = Gzip
+		}
+		accepted[coding] = q
+	}
+	return accepted
+}
+
+// qvalue returns the qvalue of coding, taking "*" and the implicit acceptability of identity into account.
+func (a acceptEncoding) qvalue(coding string) float64 {
+	if q, ok := a[coding]; ok {
+		return q
+	}
+	if q, ok := a["*"]; ok {
+		return q
+	}
+	if coding == Identity {
+		// Acceptable unless excluded, but less preferred than any coding listed by the client.
+		return 0.001
+	}
+	return 0
+}
+
+// parseQualityElement parses a single list element with an optional weight, like "gzip;q=0.5".
+// The returned value is lower-cased.
+// Parameters other than q are ignored.
+// It returns false if the weight is not a valid qvalue.
+func parseQualityElement(element string) (value string, q float64, ok bool) {
+	parts := strings.Split(element, ";")
+	value = strings.ToLower(strings.TrimSpace(parts[0]))
+	q = 1
+	for _, param := range parts[1:] {
+		param = strings.TrimSpace(param)
+		if len(param) < 2 || (param[0] != 'q' && param[0] != 'Q') || param[1] != '=' {
+			continue
+		}
+		var err error
+		if q, err = strconv.ParseFloat(param[2:], 64); err != nil || q < 0 || q > 1 {
+			return "", 0, false
+		}
+	}
+	return value, q, true
+}
+
+// representationETag returns the entity-tag of the representation of this entry with the given content coding.
+// Different content codings are different representations and thus must not share a strong entity-tag,
+// so the content coding is appended to the opaque-tag of compressed representations.
+func representationETag(etag string, coding string) string {
+	if coding == Identity || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + coding + `"`
+}
+
+func compressBrotli(data []byte) []byte {
+	return compressBrotliLevel(data, brotli.DefaultCompression)
+}
+
+func compressBrotliLevel(data []byte, level int) []byte {
+	var b bytes.Buffer
+	br := brotli.NewWriterLevel(&b, level)
+	if _, err := br.Write(data); err != nil {
+		panic(err)
+	}
+	if err := br.Close(); err != nil {
+		panic(err)
+	}
+	return b.Bytes()
+}
+
+func compressZstd(data []byte) []byte {
+	return compressZstdLevel(data, zstd.SpeedDefault)
+}
+
+func compressZstdLevel(data []byte, level zstd.EncoderLevel) []byte {
+	zw, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = zw.Close() }()
+	return zw.EncodeAll(data, nil)
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/nelkinda/http-go/header"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	encodings := []encoding{{Brotli, make([]byte, 10)}, {Zstd, make([]byte, 12)}, {Gzip, make([]byte, 15)}, {Identity, make([]byte, 100)}}
+	tests := []struct {
+		acceptEncoding []string
+		coding         string
+		ok             bool
+	}{
+		{nil, Identity, true},
+		{[]string{""}, Identity, true},
+		{[]string{"gzip"}, Gzip, true},
+		{[]string{"x-gzip"}, Gzip, true},
+		{[]string{"GZIP"}, Gzip, true},
+		{[]string{"br, zstd, gzip"}, Brotli, true},
+		{[]string{"gzip;q=0.5, br;q=0.4"}, Gzip, true},
+		{[]string{"zstd;q=1, br;q=0.9"}, Zstd, true},
+		{[]string{"gzip", "zstd"}, Zstd, true},
+		{[]string{"*"}, Brotli, true},
+		{[]string{"*;q=0"}, "", false},
+		{[]string{"identity;q=0"}, "", false},
+		{[]string{"*;q=0, identity"}, Identity, true},
+		{[]string{"gzip;q=2"}, Identity, true},
+		{[]string{"compress"}, Identity, true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/x", nil)
+		if test.acceptEncoding != nil {
+			r.Header[header.AcceptEncoding] = test.acceptEncoding
+		}
+		enc, ok := negotiateEncoding(r, encodings)
+		if enc.coding != test.coding || ok != test.ok {
+			t.Errorf("negotiateEncoding(%q) = %q, %v, want %q, %v", test.acceptEncoding, enc.coding, ok, test.coding, test.ok)
+		}
+	}
+}
+
+func TestServeEncoding(t *testing.T) {
+	body := strings.Repeat("hello world ", 100)
+	entry := &Entry{URI: "x", Body: []byte(body), ContentType: "text/plain"}
+	entry.prepare()
+	decoders := map[string]func(r io.Reader) (io.Reader, error){
+		Identity: func(r io.Reader) (io.Reader, error) { return r, nil },
+		Gzip:     func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		Brotli:   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		Zstd:     func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+	etags := make(map[string]string)
+	for coding, decode := range decoders {
+		w := doRequest(http.HandlerFunc(entry.Serve), http.MethodGet, "/x", header.AcceptEncoding, coding)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d", coding, w.Code)
+		}
+		if got := w.Header().Get(header.ContentEncoding); coding == Identity && got != "" || coding != Identity && got != coding {
+			t.Errorf("%s: got Content-Encoding %q", coding, got)
+		}
+		if got := w.Header().Get(header.Vary); !strings.Contains(got, header.AcceptEncoding) {
+			t.Errorf("%s: got Vary %q", coding, got)
+		}
+		reader, err := decode(bytes.NewReader(w.Body.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", coding, err)
+		}
+		if decoded, err := ioutil.ReadAll(reader); err != nil || string(decoded) != body {
+			t.Errorf("%s: body does not decode to the original: %v", coding, err)
+		}
+		etag := w.Header().Get(header.ETag)
+		for other, otherETag := range etags {
+			if etag == otherETag {
+				t.Errorf("%s and %s share the ETag %s", coding, other, etag)
+			}
+		}
+		etags[coding] = etag
+
+		w = doRequest(http.HandlerFunc(entry.Serve), http.MethodGet, "/x", header.AcceptEncoding, coding, header.IfNoneMatch, etag)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%s: got status %d for its own ETag, want 304", coding, w.Code)
+		}
+	}
+
+	w := doRequest(http.HandlerFunc(entry.Serve), http.MethodGet, "/x", header.AcceptEncoding, "identity;q=0")
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("got status %d without acceptable encoding, want 406", w.Code)
+	}
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"html"
+	"net/http"
+	"strconv"
+)
+
+// The header field which forbids clients to guess a content type other than the one sent.
+const xContentTypeOptions = "X-Content-Type-Options"
+
+// problem is a problem details object as per RFC 7807.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// SetErrorEntry sets the entry served for error responses with the given status code, like http.StatusNotFound.
+// If entry is nil, the error entry for status is removed.
+// The entry is not served under its URI, and is not subject to the limits of the cache.
+// The representation is negotiated as usual, so an entry with an HTML body and an application/problem+json variant
+// serves both browsers and API clients.
+// Unless the entry has its own CacheControl, it is served with no-cache.
+// The cache keeps a copy of entry, so entry itself is left unchanged, and may also be added to the cache.
+func (c *Cache) SetErrorEntry(status int, entry *Entry) {
+	if entry != nil {
+		entry = entry.preparedError()
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if entry == nil {
+		delete(c.errorEntries, status)
+		return
+	}
+	if c.errorEntries == nil {
+		c.errorEntries = make(map[int]*Entry)
+	}
+	c.errorEntries[status] = entry
+}
+
+func SetErrorEntry(status int, entry *Entry) {
+	GlobalCache.SetErrorEntry(status, entry)
+}
+
+// preparedError returns a copy of this entry and its variants, prepared for serving as error entry.
+func (e *Entry) preparedError() *Entry {
+	prepared := *e
+	if e.Variants != nil {
+		prepared.Variants = make([]*Entry, len(e.Variants))
+		for i, variant := range e.Variants {
+			prepared.Variants[i] = variant.preparedError()
+		}
+	}
+	if prepared.CacheControl == nil {
+		prepared.CacheControl = &CacheControl{NoCache: true}
+	}
+	prepared.prepare()
+	return &prepared
+}
+
+// ServeError serves an error response with the given status code.
+// If there is an error entry for status, see SetErrorEntry, it is served.
+// Otherwise, the body is generated according to the Accept header field of r:
+// application/problem+json as per RFC 7807 for clients which prefer it or application/json, HTML for browsers, and plain text otherwise.
+func (c *Cache) ServeError(w http.ResponseWriter, r *http.Request, status int) {
+	c.serveError(w, r, status, "")
+}
+
+func ServeError(w http.ResponseWriter, r *http.Request, status int) {
+	GlobalCache.ServeError(w, r, status)
+}
+
+// serveError serves an error response with the given status code like ServeError, with the URIs of entries under prefix.
+func (c *Cache) serveError(w http.ResponseWriter, r *http.Request, status int, prefix string) {
+	c.mutex.RLock()
+	entry, ok := c.errorEntries[status]
+	c.mutex.RUnlock()
+	if ok {
+		entry.serveWithStatus(w, r, c, prefix, status)
+		return
+	}
+	accept := parseQualityList(r.Header.Values(header.Accept))
+	textQ := mediaTypeQuality(accept, mimetype.TextPlain)
+	htmlQ := mediaTypeQuality(accept, mimetype.TextHtml)
+	problemQ := mediaTypeQuality(accept, mimetype.ApplicationProblemJson)
+	if jsonQ := mediaTypeQuality(accept, mimetype.ApplicationJson); jsonQ > problemQ {
+		problemQ = jsonQ
+	}
+	var contentType string
+	var body []byte
+	title := http.StatusText(status)
+	switch {
+	case problemQ > textQ && problemQ > htmlQ:
+		contentType = mimetype.ApplicationProblemJson
+		var err error
+		if body, err = json.Marshal(problem{Type: "about:blank", Title: title, Status: status, Instance: r.URL.Path}); err != nil {
+			panic(err)
+		}
+	case htmlQ > textQ:
+		contentType = mimetype.TextHtml + "; charset=utf-8"
+		body = []byte(fmt.Sprintf("<!DOCTYPE html>\n<html><head><title>%d %s</title></head><body><h1>%d %s</h1></body></html>\n", status, html.EscapeString(title), status, html.EscapeString(title)))
+	default:
+		contentType = mimetype.TextPlain + "; charset=utf-8"
+		body = []byte(strconv.Itoa(status) + " " + title + "\n")
+	}
+	h := w.Header()
+	h.Set(header.ContentType, contentType)
+	h.Set(header.ContentLength, strconv.Itoa(len(body)))
+	h.Set(header.CacheControl, "no-cache")
+	h.Set(header.Vary, header.Accept)
+	h.Set(xContentTypeOptions, "nosniff")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+// serveWithStatus serves this entry for r with the given status code instead of 200 OK, as for an error response.
+// Error responses are not counted as hits, and fall back to identity if none of the content codings accepted by the client is available.
+func (e *Entry) serveWithStatus(w http.ResponseWriter, r *http.Request, c *Cache, prefix string, status int) {
+	sw := &statusWriter{ResponseWriter: w, status: status}
+	e.serve(sw, errorRequest(r), c, prefix, false)
+	if !sw.wroteHeader {
+		// HEAD requests have no body, so nothing triggered the status.
+		sw.WriteHeader(http.StatusOK)
+	}
+}
+
+// errorRequest returns a copy of r for serving an error entry, without conditional and range header fields,
+// and with the method GET unless it is HEAD.
+func errorRequest(r *http.Request) *http.Request {
+	clone := r.Clone(r.Context())
+	for _, name := range conditionalHeaders {
+		clone.Header.Del(name)
+	}
+	if clone.Method != http.MethodHead {
+		clone.Method = http.MethodGet
+	}
+	return clone
+}
+
+// statusWriter replaces the status code 200 OK with status.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status == http.StatusOK {
+		status = w.status
+		w.Header().Del(header.AcceptRanges)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
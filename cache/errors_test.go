@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServeError(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", mimetype.TextPlain + "; charset=utf-8", "404 Not Found\n"},
+		{"text/html,*/*;q=0.8", mimetype.TextHtml + "; charset=utf-8", "<h1>404 Not Found</h1>"},
+		{mimetype.ApplicationJson, mimetype.ApplicationProblemJson, `{"type":"about:blank","title":"Not Found","status":404,"instance":"/missing"}`},
+		{mimetype.ApplicationProblemJson, mimetype.ApplicationProblemJson, `"status":404`},
+	}
+	for _, test := range tests {
+		w := doRequest(New().CacheHandler(nil), http.MethodGet, "/missing", header.Accept, test.accept)
+		h := w.Header()
+		if w.Code != http.StatusNotFound || h.Get(header.ContentType) != test.contentType || !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("Accept %q: got %d %q %q", test.accept, w.Code, h.Get(header.ContentType), w.Body.String())
+		}
+		if h.Get(header.CacheControl) != "no-cache" || h.Get(header.Vary) != header.Accept || h.Get(xContentTypeOptions) != "nosniff" {
+			t.Errorf("Accept %q: got header %v", test.accept, h)
+		}
+	}
+}
+
+func TestErrorEntry(t *testing.T) {
+	c := New()
+	entry := &Entry{URI: "404.html", Body: []byte("<p>nope</p>"), ContentType: "text/html",
+		Variants: []*Entry{{Body: []byte(`{"status":404}`), ContentType: mimetype.ApplicationProblemJson}}}
+	c.SetErrorEntry(http.StatusNotFound, entry)
+	if entry.CacheControl != nil || entry.Variants[0].CacheControl != nil || entry.ETag != "" {
+		t.Error("SetErrorEntry changed the entry")
+	}
+	handler := c.CacheHandler(nil)
+	tests := []struct {
+		method string
+		fields []string
+		body   string
+	}{
+		{http.MethodGet, nil, "<p>nope</p>"},
+		{http.MethodGet, []string{header.IfNoneMatch, "*"}, "<p>nope</p>"},
+		{http.MethodHead, nil, ""},
+		{http.MethodPost, nil, "<p>nope</p>"},
+		{http.MethodGet, []string{header.Accept, mimetype.ApplicationProblemJson}, `{"status":404}`},
+	}
+	for _, test := range tests {
+		w := doRequest(handler, test.method, "/missing", test.fields...)
+		if w.Code != http.StatusNotFound || w.Body.String() != test.body {
+			t.Errorf("%s %v: got %d %q, want 404 %q", test.method, test.fields, w.Code, w.Body.String(), test.body)
+		}
+		if got := w.Header().Get(header.CacheControl); got != "no-cache" {
+			t.Errorf("%s %v: got Cache-Control %q", test.method, test.fields, got)
+		}
+	}
+
+	c.Add(entry)
+	if w := doRequest(handler, http.MethodGet, "/404.html"); w.Code != http.StatusOK || w.Header().Get(header.CacheControl) == "no-cache" {
+		t.Errorf("got %d, Cache-Control %q for the entry added to the cache", w.Code, w.Header().Get(header.CacheControl))
+	}
+
+	c.SetErrorEntry(http.StatusNotFound, nil)
+	if w := doRequest(handler, http.MethodGet, "/missing"); w.Body.String() != "404 Not Found\n" {
+		t.Errorf("got %q after removing the error entry", w.Body.String())
+	}
+}
+
+func TestErrorEntryIgnoresUnacceptableEncodings(t *testing.T) {
+	c := New()
+	c.SetErrorEntry(http.StatusNotFound, &Entry{Body: []byte("gone"), ContentType: "text/plain"})
+	w := doRequest(c.CacheHandler(nil), http.MethodGet, "/missing", header.AcceptEncoding, "identity;q=0")
+	if w.Code != http.StatusNotFound || w.Body.String() != "gone" || w.Header().Get(header.ContentEncoding) != "" {
+		t.Errorf("got %d %q, Content-Encoding %q", w.Code, w.Body.String(), w.Header().Get(header.ContentEncoding))
+	}
+	c.Add(&Entry{URI: "page", Body: []byte("x"), ContentType: "text/plain"})
+	if w := doRequest(c.CacheHandler(nil), http.MethodGet, "/page", header.AcceptEncoding, "identity;q=0"); w.Code != http.StatusNotAcceptable {
+		t.Errorf("got status %d for an entry", w.Code)
+	}
+}
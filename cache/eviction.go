@@ -0,0 +1,344 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"time"
+)
+
+// Limits bounds the memory used by a cache.
+// When a cache exceeds its limits, entries are evicted as decided by the Policy.
+// Pinned entries count towards the limits, but are never evicted.
+type Limits struct {
+
+	// The maximum number of bytes used by the bodies of all entries, or 0 for no limit.
+	MaxBytes int
+
+	// The maximum number of entries, or 0 for no limit.
+	MaxEntries int
+
+	// The eviction policy.
+	// If nil, and MaxBytes or MaxEntries are set, NewLRU is used.
+	Policy EvictionPolicy
+
+	// OnEvict, if not nil, is called for every evicted or expired entry, after it has been removed from the cache.
+	// It may be used to log evictions or to re-populate the cache.
+	OnEvict func(entry *Entry)
+}
+
+// EvictionPolicy decides which entries to evict when a cache exceeds its limits.
+// A cache serializes all calls to its policy, so implementations need no synchronization of their own.
+// Implementations must not call the cache.
+// Pinned entries are never passed to a policy.
+type EvictionPolicy interface {
+
+	// Added is called when an entry was added to the cache.
+	Added(entry *Entry)
+
+	// Accessed is called when an entry was retrieved from the cache.
+	Accessed(entry *Entry)
+
+	// Removed is called when an entry was removed from the cache, including its eviction.
+	Removed(entry *Entry)
+
+	// Victim returns the entry to evict next, or nil if there is none.
+	Victim() *Entry
+}
+
+// Expirer is implemented by eviction policies which also expire entries regardless of the limits.
+type Expirer interface {
+
+	// Expired returns the entries which have expired at the given time.
+	Expired(now time.Time) []*Entry
+}
+
+// SetLimits sets the limits of this cache, and evicts entries right away if the cache exceeds them.
+// The entries of the cache are passed to a policy only when it is new to the cache,
+// so the same policy may be passed again to change the other limits.
+func (c *Cache) SetLimits(limits Limits) {
+	if limits.Policy == nil && (limits.MaxBytes > 0 || limits.MaxEntries > 0) {
+		limits.Policy = NewLRU()
+	}
+	c.mutex.Lock()
+	isNew := limits.Policy != c.limits.Policy
+	c.limits = limits
+	if limits.Policy != nil && isNew {
+		for _, entry := range sortedEntries(c.entries) {
+			if !entry.Pinned {
+				limits.Policy.Added(entry)
+			}
+		}
+	}
+	evicted := c.evict()
+	c.mutex.Unlock()
+	c.notifyEvicted(evicted)
+}
+
+func SetLimits(limits Limits) {
+	GlobalCache.SetLimits(limits)
+}
+
+// maxBytes returns the MaxBytes of the limits of this cache.
+func (c *Cache) maxBytes() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.limits.MaxBytes
+}
+
+// overLimits returns true if this cache exceeds its limits.
+// c.mutex must be held.
+func (c *Cache) overLimits() bool {
+	return c.limits.MaxBytes > 0 && c.bytes > c.limits.MaxBytes || c.limits.MaxEntries > 0 && len(c.entries) > c.limits.MaxEntries
+}
+
+// hasExpired returns true if the policy of this cache has expired entries.
+// c.mutex must be held.
+func (c *Cache) hasExpired() bool {
+	expirer, ok := c.limits.Policy.(Expirer)
+	if !ok {
+		return false
+	}
+	c.policyMutex.Lock()
+	defer c.policyMutex.Unlock()
+	return len(expirer.Expired(time.Now())) > 0
+}
+
+// evict removes expired entries and evicts entries until this cache no longer exceeds its limits.
+// It returns the removed entries, which must be passed to notifyEvicted after c.mutex is released.
+// c.mutex must be held for writing.
+func (c *Cache) evict() []*Entry {
+	policy := c.limits.Policy
+	if policy == nil {
+		return nil
+	}
+	var evicted []*Entry
+	if expirer, ok := policy.(Expirer); ok {
+		c.policyMutex.Lock()
+		expired := expirer.Expired(time.Now())
+		c.policyMutex.Unlock()
+		for _, entry := range expired {
+			if c.evictEntry(entry) {
+				evicted = append(evicted, entry)
+			}
+		}
+	}
+	// The victims which were no longer in the cache, to stop if a policy makes no progress dropping them.
+	var stale map[*Entry]bool
+	for c.overLimits() {
+		c.policyMutex.Lock()
+		victim := policy.Victim()
+		c.policyMutex.Unlock()
+		if victim == nil || stale[victim] {
+			break
+		}
+		if !c.evictEntry(victim) {
+			if stale == nil {
+				stale = make(map[*Entry]bool)
+			}
+			stale[victim] = true
+			continue
+		}
+		evicted = append(evicted, victim)
+	}
+	return evicted
+}
+
+// evictEntry removes entry from this cache, and returns false if entry was not in this cache.
+// c.mutex must be held for writing.
+func (c *Cache) evictEntry(entry *Entry) bool {
+	if c.entries[entry.URI] == entry {
+		c.delete(entry.URI)
+		return true
+	}
+	// The policy is out of sync, make sure it does not return the same victim again.
+	c.policyMutex.Lock()
+	c.limits.Policy.Removed(entry)
+	c.policyMutex.Unlock()
+	return false
+}
+
+// notifyEvicted records the evicted entries and calls the OnEvict callback for them.
+// c.mutex must not be held.
+func (c *Cache) notifyEvicted(evicted []*Entry) {
+	if len(evicted) == 0 {
+		return
+	}
+	c.recordEvicted(evicted)
+	c.mutex.RLock()
+	onEvict := c.limits.OnEvict
+	c.mutex.RUnlock()
+	if onEvict == nil {
+		return
+	}
+	for _, entry := range evicted {
+		onEvict(entry)
+	}
+}
+
+// lru is the least recently used eviction policy.
+type lru struct {
+	order    *list.List
+	elements map[*Entry]*list.Element
+}
+
+// NewLRU returns an eviction policy which evicts the least recently used entry first.
+func NewLRU() EvictionPolicy {
+	return &lru{order: list.New(), elements: make(map[*Entry]*list.Element)}
+}
+
+func (p *lru) Added(entry *Entry) {
+	p.elements[entry] = p.order.PushBack(entry)
+}
+
+func (p *lru) Accessed(entry *Entry) {
+	if element, ok := p.elements[entry]; ok {
+		p.order.MoveToBack(element)
+	}
+}
+
+func (p *lru) Removed(entry *Entry) {
+	if element, ok := p.elements[entry]; ok {
+		p.order.Remove(element)
+		delete(p.elements, entry)
+	}
+}
+
+func (p *lru) Victim() *Entry {
+	if front := p.order.Front(); front != nil {
+		return front.Value.(*Entry)
+	}
+	return nil
+}
+
+// lfu is the least frequently used eviction policy.
+// It is a heap ordered by access count, and for equal access counts by the time of the last access.
+type lfu struct {
+	items []*lfuItem
+	index map[*Entry]*lfuItem
+	clock int64
+}
+
+type lfuItem struct {
+	entry      *Entry
+	count      int64
+	lastAccess int64
+	position   int
+}
+
+// NewLFU returns an eviction policy which evicts the least frequently used entry first.
+// Among entries used equally often, the least recently used one is evicted first.
+// New entries start with the access count of the least frequently used entry, so that they are not evicted right away.
+func NewLFU() EvictionPolicy {
+	return &lfu{index: make(map[*Entry]*lfuItem)}
+}
+
+func (p *lfu) Len() int { return len(p.items) }
+
+func (p *lfu) Less(i, j int) bool {
+	if p.items[i].count != p.items[j].count {
+		return p.items[i].count < p.items[j].count
+	}
+	return p.items[i].lastAccess < p.items[j].lastAccess
+}
+
+func (p *lfu) Swap(i, j int) {
+	p.items[i], p.items[j] = p.items[j], p.items[i]
+	p.items[i].position = i
+	p.items[j].position = j
+}
+
+func (p *lfu) Push(x interface{}) {
+	item := x.(*lfuItem)
+	item.position = len(p.items)
+	p.items = append(p.items, item)
+}
+
+func (p *lfu) Pop() interface{} {
+	item := p.items[len(p.items)-1]
+	p.items = p.items[:len(p.items)-1]
+	return item
+}
+
+func (p *lfu) Added(entry *Entry) {
+	p.clock++
+	item := &lfuItem{entry: entry, lastAccess: p.clock}
+	if len(p.items) > 0 {
+		item.count = p.items[0].count
+	}
+	p.index[entry] = item
+	heap.Push(p, item)
+}
+
+func (p *lfu) Accessed(entry *Entry) {
+	if item, ok := p.index[entry]; ok {
+		p.clock++
+		item.count++
+		item.lastAccess = p.clock
+		heap.Fix(p, item.position)
+	}
+}
+
+func (p *lfu) Removed(entry *Entry) {
+	if item, ok := p.index[entry]; ok {
+		heap.Remove(p, item.position)
+		delete(p.index, entry)
+	}
+}
+
+func (p *lfu) Victim() *Entry {
+	if len(p.items) > 0 {
+		return p.items[0].entry
+	}
+	return nil
+}
+
+// ttl is the time-to-live eviction policy.
+type ttl struct {
+	ttl      time.Duration
+	order    *list.List
+	elements map[*Entry]*list.Element
+}
+
+type ttlItem struct {
+	entry *Entry
+	added time.Time
+}
+
+// NewTTL returns an eviction policy which expires entries the given time after they were added.
+// When the cache exceeds its limits before entries expire, the oldest entry is evicted first.
+func NewTTL(timeToLive time.Duration) EvictionPolicy {
+	return &ttl{ttl: timeToLive, order: list.New(), elements: make(map[*Entry]*list.Element)}
+}
+
+func (p *ttl) Added(entry *Entry) {
+	p.elements[entry] = p.order.PushBack(&ttlItem{entry: entry, added: time.Now()})
+}
+
+func (p *ttl) Accessed(*Entry) {
+}
+
+func (p *ttl) Removed(entry *Entry) {
+	if element, ok := p.elements[entry]; ok {
+		p.order.Remove(element)
+		delete(p.elements, entry)
+	}
+}
+
+func (p *ttl) Victim() *Entry {
+	if front := p.order.Front(); front != nil {
+		return front.Value.(*ttlItem).entry
+	}
+	return nil
+}
+
+func (p *ttl) Expired(now time.Time) []*Entry {
+	var expired []*Entry
+	for element := p.order.Front(); element != nil; element = element.Next() {
+		item := element.Value.(*ttlItem)
+		if now.Sub(item.added) < p.ttl {
+			break
+		}
+		expired = append(expired, item.entry)
+	}
+	return expired
+}
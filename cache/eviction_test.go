@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cachedURIs returns the URIs of the entries of c, sorted.
+func cachedURIs(c *Cache) string {
+	var uris []string
+	for _, entry := range c.Entries() {
+		uris = append(uris, entry.URI)
+	}
+	return strings.Join(uris, " ")
+}
+
+func TestLRU(t *testing.T) {
+	var evicted []string
+	c := New()
+	c.Add(&Entry{URI: "pinned", Pinned: true})
+	c.SetLimits(Limits{MaxEntries: 3, OnEvict: func(entry *Entry) { evicted = append(evicted, entry.URI) }})
+	c.Add(&Entry{URI: "a"})
+	c.Add(&Entry{URI: "b"})
+	c.Get("a")
+	c.Add(&Entry{URI: "c"})
+	if got := cachedURIs(c); got != "a c pinned" {
+		t.Errorf("got entries %q", got)
+	}
+	if fmt.Sprint(evicted) != "[b]" {
+		t.Errorf("got evicted %v", evicted)
+	}
+}
+
+func TestLFU(t *testing.T) {
+	c := New()
+	c.SetLimits(Limits{MaxEntries: 2, Policy: NewLFU()})
+	c.Add(&Entry{URI: "a"})
+	c.Add(&Entry{URI: "b"})
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	c.Add(&Entry{URI: "c"})
+	if got := cachedURIs(c); got != "a c" {
+		t.Errorf("got entries %q", got)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	var evicted []string
+	c := New()
+	c.SetLimits(Limits{Policy: NewTTL(20 * time.Millisecond), OnEvict: func(entry *Entry) { evicted = append(evicted, entry.URI) }})
+	c.Add(&Entry{URI: "a"})
+	c.Add(&Entry{URI: "pinned", Pinned: true})
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a expired too early")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a did not expire")
+	}
+	if _, ok := c.Get("pinned"); !ok {
+		t.Error("pinned entry expired")
+	}
+	if fmt.Sprint(evicted) != "[a]" {
+		t.Errorf("got evicted %v", evicted)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	c := New()
+	entry := func(uri string) *Entry {
+		return &Entry{URI: uri, Body: make([]byte, 100), GzipBody: []byte{}, BrotliBody: []byte{}, ZstdBody: []byte{}}
+	}
+	c.SetLimits(Limits{MaxBytes: 250})
+	c.Add(entry("a"))
+	c.Add(entry("b"))
+	c.Add(entry("c"))
+	if entries, memory := c.Size(); entries != 2 || memory != 200 {
+		t.Errorf("got size %d, %d, want 2, 200", entries, memory)
+	}
+	if got := cachedURIs(c); got != "b c" {
+		t.Errorf("got entries %q", got)
+	}
+}
+
+// TestSetLimitsAgain checks that passing the same policy again does not add the entries to it twice,
+// which made a later eviction loop forever.
+func TestSetLimitsAgain(t *testing.T) {
+	c := New()
+	for i := 0; i < 5; i++ {
+		c.Add(&Entry{URI: fmt.Sprint(i)})
+	}
+	limits := Limits{MaxEntries: 10, Policy: NewLRU()}
+	c.SetLimits(limits)
+	c.SetLimits(limits)
+	limits.MaxEntries = 1
+	done := make(chan struct{})
+	go func() {
+		c.SetLimits(limits)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SetLimits did not return")
+	}
+	if entries, _ := c.Size(); entries != 1 {
+		t.Errorf("got %d entries, want 1", entries)
+	}
+}
+
+// staticPolicy is an eviction policy which always returns the same victim, even after it was removed.
+type staticPolicy struct {
+	victim *Entry
+}
+
+func (p *staticPolicy) Added(*Entry)    {}
+func (p *staticPolicy) Accessed(*Entry) {}
+func (p *staticPolicy) Victim() *Entry  { return p.victim }
+func (p *staticPolicy) Removed(*Entry)  {}
+
+// TestEvictPolicyOutOfSync checks that eviction skips victims which are not in the cache,
+// and stops if the policy makes no progress.
+func TestEvictPolicyOutOfSync(t *testing.T) {
+	c := New()
+	c.Add(&Entry{URI: "a"})
+	c.Add(&Entry{URI: "b"})
+	policy := NewLRU()
+	policy.Added(&Entry{URI: "a"})
+	c.SetLimits(Limits{MaxEntries: 1, Policy: policy})
+	if got := cachedURIs(c); got != "b" {
+		t.Errorf("got entries %q, want b", got)
+	}
+
+	c = New()
+	c.Add(&Entry{URI: "a"})
+	c.Add(&Entry{URI: "b"})
+	done := make(chan struct{})
+	go func() {
+		c.SetLimits(Limits{MaxEntries: 1, Policy: &staticPolicy{victim: &Entry{URI: "stranger"}}})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SetLimits did not return")
+	}
+	if entries, _ := c.Size(); entries != 2 {
+		t.Errorf("got %d entries, want 2", entries)
+	}
+}
@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+)
+
+// fileBody is the body of a file-backed entry, which stays on disk instead of in memory.
+type fileBody struct {
+
+	// The size of the file when the entry was created.
+	size int64
+
+	// The modification time of the file when the entry was created.
+	modTime time.Time
+
+	// The memory-mapped content of the file, or nil if the file is read when the entry is served.
+	mapped []byte
+
+	// The open file which is memory-mapped, kept open to tell whether it was modified in place, or nil.
+	mappedFile *os.File
+}
+
+// NewFileEntry creates a file-backed entry for the given file, which keeps only its metadata in memory.
+// Such an entry is meant for large files like downloads and videos, which would dominate the memory use of the cache.
+// The file is read once to compute the ETag, the Subresource Integrity values and the digests,
+// and streamed from disk whenever the entry is served, which lets the server use sendfile.
+// With mmap, the file is memory-mapped on Linux instead, and served from the page cache; on other platforms, mmap is ignored.
+// Only use mmap for files which are never modified in place, but replaced, for example by renaming a new file over the old one:
+// if a memory-mapped file is truncated, reading the lost part crashes the whole process with SIGBUS, which cannot be recovered.
+// A memory-mapped file which was replaced is still served from the mapping of the old file until the entry is reloaded.
+// The mapped file is checked before it is served, but that cannot rule out a truncation while it is served.
+// If the file of the entry was modified since the entry was created, requests for the entry are answered with 500 Internal Server Error until it is reloaded.
+// File-backed entries are served uncompressed, and support range requests and conditional requests like other entries.
+// The entry remembers filename, so Reload and Watch update it when the file changes.
+func NewFileEntry(filename string, uri string, contentType string, mmap bool) (*Entry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if file != nil {
+			_ = file.Close()
+		}
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	md5Hash, sha256Hash, sha384Hash, sha512Hash := md5.New(), sha256.New(), sha512.New384(), sha512.New()
+	size, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash, sha384Hash, sha512Hash), file)
+	if err != nil {
+		return nil, err
+	}
+	body := &fileBody{size: size, modTime: info.ModTime()}
+	if mmap {
+		if body.mapped, err = mmapFile(file, size); err != nil {
+			return nil, err
+		}
+		if body.mapped != nil {
+			body.mappedFile, file = file, nil
+			runtime.SetFinalizer(body, func(body *fileBody) {
+				_ = munmap(body.mapped)
+				_ = body.mappedFile.Close()
+			})
+		}
+	}
+	modTime := info.ModTime().UTC()
+	return &Entry{
+		URI:          uri,
+		ContentType:  contentType,
+		LastModified: &modTime,
+		ETag:         `"` + hex.EncodeToString(md5Hash.Sum(nil)) + `"`,
+		Integrity: map[string]string{
+			SHA256: SHA256 + "-" + base64.StdEncoding.EncodeToString(sha256Hash.Sum(nil)),
+			SHA384: SHA384 + "-" + base64.StdEncoding.EncodeToString(sha384Hash.Sum(nil)),
+			SHA512: SHA512 + "-" + base64.StdEncoding.EncodeToString(sha512Hash.Sum(nil)),
+		},
+		Filename: filename,
+		file:     body,
+		digests:  map[string][]byte{Identity: sha256Hash.Sum(nil)},
+	}, nil
+}
+
+// AddFile adds a file-backed entry for the given file, see NewFileEntry.
+func (c *Cache) AddFile(filename string, uri string, contentType string, maxAge time.Duration, mmap bool) error {
+	entry, err := NewFileEntry(filename, uri, contentType, mmap)
+	if err != nil {
+		return err
+	}
+	entry.MaxAge = maxAge
+	c.Add(entry)
+	return nil
+}
+
+func AddFile(filename string, uri string, contentType string, maxAge time.Duration, mmap bool) error {
+	return GlobalCache.AddFile(filename, uri, contentType, maxAge, mmap)
+}
+
+// IsFileBacked returns true if the body of this entry stays on disk, see NewFileEntry.
+func (e *Entry) IsFileBacked() bool {
+	return e.file != nil
+}
+
+// bodySize returns the size of the uncompressed body of this entry.
+func (e *Entry) bodySize() int64 {
+	if e.file != nil {
+		return e.file.size
+	}
+	return int64(len(e.Body))
+}
+
+// readBody returns the uncompressed body of this entry, reading it from disk for file-backed entries.
+func (e *Entry) readBody() ([]byte, error) {
+	if e.file == nil {
+		return e.Body, nil
+	}
+	if e.file.mapped != nil {
+		if err := e.file.checkMapped(); err != nil {
+			return nil, err
+		}
+		body := append([]byte(nil), e.file.mapped...)
+		runtime.KeepAlive(e.file)
+		return body, nil
+	}
+	return ioutil.ReadFile(e.Filename)
+}
+
+// errFileChanged is returned when the file of a file-backed entry changed since the entry was created.
+var errFileChanged = errors.New("file changed since the entry was created")
+
+// changed returns true if the file described by info differs from the file of this body.
+func (b *fileBody) changed(info os.FileInfo) bool {
+	return info.Size() != b.size || !info.ModTime().Equal(b.modTime)
+}
+
+// checkMapped returns errFileChanged if the memory-mapped file was modified in place since it was mapped.
+// The open file is checked instead of the filename, so a file which was replaced leaves the mapping valid.
+func (b *fileBody) checkMapped() error {
+	info, err := b.mappedFile.Stat()
+	if err != nil {
+		return err
+	}
+	if b.changed(info) {
+		return errFileChanged
+	}
+	return nil
+}
+
+// content is the body of a representation of an entry while it is served.
+type content struct {
+	size int64
+
+	// The body in memory, or nil if it is read from file.
+	body []byte
+
+	// The open file of a file-backed entry which is not memory-mapped, or nil.
+	file *os.File
+
+	// The body of a file-backed entry, kept reachable while its memory-mapped content is served.
+	fileBody *fileBody
+}
+
+// open returns the content of the representation of this entry with the given encoding.
+// The content must be closed after serving.
+// For file-backed entries, errFileChanged is returned if the file changed since the entry was created.
+func (e *Entry) open(enc encoding) (*content, error) {
+	if enc.coding != Identity || e.file == nil {
+		return &content{size: int64(len(enc.body)), body: enc.body}, nil
+	}
+	if e.file.mapped != nil {
+		if err := e.file.checkMapped(); err != nil {
+			return nil, err
+		}
+		return &content{size: e.file.size, body: e.file.mapped, fileBody: e.file}, nil
+	}
+	file, err := os.Open(e.Filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err == nil && e.file.changed(info) {
+		err = errFileChanged
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &content{size: e.file.size, file: file}, nil
+}
+
+// write writes length bytes of this content, starting at start, to w.
+// Files are copied with io.CopyN, so that w can use sendfile if it is an io.ReaderFrom like the http.ResponseWriter of net/http.
+// An error means that the content was not written completely.
+func (c *content) write(w io.Writer, start int64, length int64) error {
+	if c.file == nil {
+		_, err := w.Write(c.body[start : start+length])
+		return err
+	}
+	if _, err := c.file.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.CopyN(w, c.file, length)
+	return err
+}
+
+// mustWrite writes length bytes of this content, starting at start, to the response w.
+// As the status and Content-Length are sent already, an error aborts the response with http.ErrAbortHandler,
+// so that the client sees the truncation instead of a response which looks complete.
+func (c *content) mustWrite(w io.Writer, start int64, length int64) {
+	if err := c.write(w, start, length); err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// close releases this content.
+func (c *content) close() {
+	if c.file != nil {
+		_ = c.file.Close()
+	}
+	runtime.KeepAlive(c.fileBody)
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileEntry(t *testing.T) {
+	body := strings.Repeat("0123456789", 1000)
+	for _, mmap := range []bool{false, true} {
+		t.Run(fmt.Sprintf("mmap=%v", mmap), func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "big.bin", body, time.Now().Add(-time.Hour))
+			entry, err := NewFileEntry(filepath.Join(dir, "big.bin"), "big.bin", "application/octet-stream", mmap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inMemory := &Entry{URI: "big.bin", Body: []byte(body), ContentType: "application/octet-stream"}
+			inMemory.prepare()
+			if !entry.IsFileBacked() || entry.ETag != inMemory.ETag || entry.Integrity[SHA384] != inMemory.Integrity[SHA384] {
+				t.Errorf("got file-backed %v, ETag %s, integrity %s", entry.IsFileBacked(), entry.ETag, entry.Integrity[SHA384])
+			}
+
+			handler := http.HandlerFunc(entry.Serve)
+			w := doRequest(handler, http.MethodGet, "/big.bin", header.AcceptEncoding, "gzip, br")
+			if w.Code != http.StatusOK || w.Body.String() != body || w.Header().Get(header.ContentEncoding) != "" {
+				t.Errorf("got %d, Content-Encoding %q, %d bytes", w.Code, w.Header().Get(header.ContentEncoding), w.Body.Len())
+			}
+			w = doRequest(handler, http.MethodGet, "/big.bin", header.Range, "bytes=5-14")
+			if w.Code != http.StatusPartialContent || w.Body.String() != "5678901234" || w.Header().Get(header.ContentRange) != "bytes 5-14/10000" {
+				t.Errorf("got %d %q, Content-Range %q", w.Code, w.Body.String(), w.Header().Get(header.ContentRange))
+			}
+			w = doRequest(handler, http.MethodGet, "/big.bin", header.Range, "bytes=0-1,-3")
+			if w.Code != http.StatusPartialContent || !strings.HasPrefix(w.Header().Get(header.ContentType), "multipart/byteranges") {
+				t.Errorf("got %d, Content-Type %q", w.Code, w.Header().Get(header.ContentType))
+			}
+			if w := doRequest(handler, http.MethodGet, "/big.bin", header.IfNoneMatch, entry.ETag); w.Code != http.StatusNotModified {
+				t.Errorf("got status %d for a matching ETag", w.Code)
+			}
+		})
+	}
+}
+
+func TestFileEntryChanged(t *testing.T) {
+	for _, mmap := range []bool{false, true} {
+		t.Run(fmt.Sprintf("mmap=%v", mmap), func(t *testing.T) {
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "f")
+			writeFile(t, dir, "f", "0123456789", time.Now().Add(-time.Hour))
+			entry, err := NewFileEntry(filename, "f", "text/plain", mmap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w := doRequest(http.HandlerFunc(entry.Serve), http.MethodGet, "/f"); w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+				t.Fatalf("got %d %q", w.Code, w.Body.String())
+			}
+			if mmap {
+				// Truncating a memory-mapped file would crash the test with SIGBUS, so only its modification time changes.
+				now := time.Now()
+				if err := os.Chtimes(filename, now, now); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				writeFile(t, dir, "f", "01234", time.Now())
+			}
+			if w := doRequest(http.HandlerFunc(entry.Serve), http.MethodGet, "/f"); w.Code != http.StatusInternalServerError {
+				t.Errorf("got status %d for a changed file", w.Code)
+			}
+		})
+	}
+}
+
+func TestMappedFileReplaced(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "f")
+	writeFile(t, dir, "f", "0123456789", time.Now().Add(-time.Hour))
+	entry, err := NewFileEntry(filename, "f", "text/plain", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "f.new", "new", time.Now())
+	if err := os.Rename(filepath.Join(dir, "f.new"), filename); err != nil {
+		t.Fatal(err)
+	}
+	if w := doRequest(http.HandlerFunc(entry.Serve), http.MethodGet, "/f"); w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Errorf("got %d %q for a replaced file, want the mapped content", w.Code, w.Body.String())
+	}
+	if body, err := entry.readBody(); err != nil || string(body) != "0123456789" {
+		t.Errorf("got %q, %v", body, err)
+	}
+}
+
+func TestContentWriteTruncated(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "f", "01234", time.Now())
+	file, err := os.Open(filepath.Join(dir, "f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := &content{size: 10, file: file}
+	defer body.close()
+	if err := body.write(ioutil.Discard, 0, 10); err == nil {
+		t.Error("got no error for a truncated file")
+	}
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("got %v, want http.ErrAbortHandler", recovered)
+		}
+	}()
+	body.mustWrite(ioutil.Discard, 0, 10)
+}
+
+func TestLoadDirFileThreshold(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFile(t, dir, "big.bin", strings.Repeat("x", 2000), modTime)
+	writeFile(t, dir, "small.css", "a{}", modTime)
+	c := New()
+	if _, err := c.LoadDir(dir, LoadOptions{FileThreshold: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	big, _ := c.Get("big.bin")
+	small, _ := c.Get("small.css")
+	if !big.IsFileBacked() || small.IsFileBacked() {
+		t.Errorf("got file-backed %v for big.bin, %v for small.css", big.IsFileBacked(), small.IsFileBacked())
+	}
+	if _, size := c.Size(); size >= 2000 {
+		t.Errorf("got size %d, want the file-backed body not counted", size)
+	}
+
+	writeFile(t, dir, "big.bin", strings.Repeat("y", 2000), modTime.Add(time.Second))
+	if _, err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if w := doRequest(c.CacheHandler(nil), http.MethodGet, "/big.bin"); w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), bytes.Repeat([]byte("y"), 2000)) {
+		t.Errorf("got %d after reload", w.Code)
+	}
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"path"
+	"strings"
+	"time"
+)
+
+// FingerprintMaxAge is the maximum cache age of fingerprinted entries.
+const FingerprintMaxAge = 365 * 24 * time.Hour
+
+// Fingerprint adds entry under a URI which contains a hash of its body, like "app.3f9a1c2b7e.js" for "app.js",
+// and returns the fingerprinted URI.
+// The original URI of entry becomes its logical name in the manifest, see AssetURI.
+// As the content of a fingerprinted URI never changes, the entry is cached publicly for FingerprintMaxAge as immutable.
+// The entry previously fingerprinted for the same logical name is removed.
+func (c *Cache) Fingerprint(entry *Entry) string {
+	name := entry.URI
+	entry.asset = name
+	entry.URI = fingerprintURI(name, entry.contentHash())
+	entry.CacheControl = &CacheControl{Public: true, Immutable: true, MaxAge: FingerprintMaxAge}
+	c.addAsset(entry)
+	return entry.URI
+}
+
+func Fingerprint(entry *Entry) string {
+	return GlobalCache.Fingerprint(entry)
+}
+
+// addAsset adds the fingerprinted entry and maps its logical name to its URI in the manifest.
+func (c *Cache) addAsset(entry *Entry) {
+	entry.prepare()
+	c.mutex.Lock()
+	if previous, ok := c.manifest[entry.asset]; ok && previous != entry.URI {
+		c.delete(previous)
+	}
+	c.put(entry)
+	if c.manifest == nil {
+		c.manifest = make(map[string]string)
+	}
+	c.manifest[entry.asset] = entry.URI
+	evicted := c.evict()
+	c.mutex.Unlock()
+	c.notifyEvicted(evicted)
+}
+
+// AssetURI returns the fingerprinted URI of the entry with the given logical name, see Fingerprint.
+// A leading slash of name is ignored.
+func (c *Cache) AssetURI(name string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	uri, ok := c.manifest[strings.TrimPrefix(name, "/")]
+	return uri, ok
+}
+
+func AssetURI(name string) (string, bool) {
+	return GlobalCache.AssetURI(name)
+}
+
+// Manifest returns a copy of the manifest, which maps the logical names of all fingerprinted entries to their URIs.
+func (c *Cache) Manifest() map[string]string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	manifest := make(map[string]string, len(c.manifest))
+	for name, uri := range c.manifest {
+		manifest[name] = uri
+	}
+	return manifest
+}
+
+func Manifest() map[string]string {
+	return GlobalCache.Manifest()
+}
+
+// FuncMap returns template functions for html/template which resolve logical asset names with this cache.
+// The function "asset" returns the absolute path of the fingerprinted URI under prefix, like "/app.3f9a1c2b7e.js" for "app.js".
+// prefix is the Prefix of the Handler which serves the entries, see HandlerOptions, or empty.
+// The function "integrity" returns the SHA384 Subresource Integrity value of an asset or entry:
+//
+//	<script src="{{asset "app.js"}}" integrity="{{integrity "app.js"}}" crossorigin="anonymous"></script>
+//
+// Both fail template execution for unknown names, so that pages never refer to assets which the cache does not serve.
+func (c *Cache) FuncMap(prefix string) template.FuncMap {
+	return template.FuncMap{
+		"asset": func(name string) (string, error) {
+			uri, ok := c.AssetURI(name)
+			if !ok {
+				return "", fmt.Errorf("unknown asset %q", name)
+			}
+			return prefixedPath(prefix, uri), nil
+		},
+		"integrity": func(name string) (string, error) {
+			value, ok := c.Integrity(name, SHA384)
+			if !ok {
+				return "", fmt.Errorf("unknown asset %q", name)
+			}
+			return value, nil
+		},
+	}
+}
+
+func FuncMap(prefix string) template.FuncMap {
+	return GlobalCache.FuncMap(prefix)
+}
+
+// contentHash returns the SHA-256 hash of the uncompressed body of this entry.
+func (e *Entry) contentHash() []byte {
+	if e.file != nil {
+		return e.digests[Identity]
+	}
+	sum := sha256.Sum256(e.Body)
+	return sum[:]
+}
+
+// fingerprintURI returns uri with the given hash of its body inserted before its extension.
+func fingerprintURI(uri string, sum []byte) string {
+	hash := hex.EncodeToString(sum[:5])
+	ext := path.Ext(uri)
+	return strings.TrimSuffix(uri, ext) + "." + hash + ext
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"html/template"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// fingerprinted returns the URI under which Fingerprint adds uri with the given body.
+func fingerprinted(uri string, body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return fingerprintURI(uri, sum[:])
+}
+
+func TestFingerprint(t *testing.T) {
+	c := New()
+	uri := c.Fingerprint(&Entry{URI: "js/app.js", Body: []byte("v1"), ContentType: "text/javascript"})
+	if uri != "js/app.3bfc269594.js" {
+		t.Fatalf("got URI %q, want the first 5 bytes of the SHA-256 hash of the body before the extension", uri)
+	}
+	if got, ok := c.AssetURI("/js/app.js"); !ok || got != uri {
+		t.Errorf("got AssetURI %q, %v", got, ok)
+	}
+	w := doRequest(c.CacheHandler(nil), http.MethodGet, "/"+uri)
+	if got := w.Header().Get(header.CacheControl); got != "public, max-age=31536000, immutable" {
+		t.Errorf("got Cache-Control %q", got)
+	}
+
+	updated := c.Fingerprint(&Entry{URI: "js/app.js", Body: []byte("v2"), ContentType: "text/javascript"})
+	if updated == uri || updated != fingerprinted("js/app.js", "v2") {
+		t.Errorf("got URI %q for new content", updated)
+	}
+	if _, ok := c.Get(uri); ok {
+		t.Error("the previous fingerprinted entry was not removed")
+	}
+	if fmt.Sprint(c.Manifest()) != fmt.Sprintf("map[js/app.js:%s]", updated) {
+		t.Errorf("got manifest %v", c.Manifest())
+	}
+}
+
+func TestFuncMap(t *testing.T) {
+	c := New()
+	uri := c.Fingerprint(&Entry{URI: "app.js", Body: []byte("v1"), ContentType: "text/javascript"})
+	tmpl := template.Must(template.New("").Funcs(c.FuncMap("")).Parse(`<script src="{{asset "app.js"}}"></script>`))
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<script src="/` + uri + `"></script>`; b.String() != want {
+		t.Errorf("got %s, want %s", b.String(), want)
+	}
+	entry, _ := c.Get(uri)
+	integrity := c.FuncMap("")["integrity"].(func(string) (string, error))
+	if value, err := integrity("app.js"); err != nil || value != entry.Integrity[SHA384] {
+		t.Errorf("got integrity %q, %v, want %q", value, err, entry.Integrity[SHA384])
+	}
+	tmpl = template.Must(template.New("").Funcs(c.FuncMap("")).Parse(`{{asset "missing.js"}}`))
+	if err := tmpl.Execute(&b, nil); err == nil {
+		t.Error("got no error for an unknown asset")
+	}
+}
+
+func TestLoadDirFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFile(t, dir, "app.js", "v1", modTime)
+	writeFile(t, dir, "index.html", "x", modTime)
+	c := New()
+	if _, err := c.LoadDir(dir, LoadOptions{Fingerprint: regexp.MustCompile(`\.js$`)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(c.Manifest()); got != fmt.Sprintf("map[app.js:%s]", fingerprinted("app.js", "v1")) {
+		t.Fatalf("got manifest %s", got)
+	}
+
+	writeFile(t, dir, "app.js", "v22", modTime.Add(time.Second))
+	summary, err := c.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2 := fingerprinted("app.js", "v22")
+	if fmt.Sprint(summary.Updated) != fmt.Sprintf("[%s]", v2) {
+		t.Errorf("got updated %v", summary.Updated)
+	}
+	if got, _ := c.AssetURI("app.js"); got != v2 {
+		t.Errorf("got AssetURI %q after reload, want %q", got, v2)
+	}
+	if got := cachedURIs(c); got != v2+" index.html" {
+		t.Errorf("got entries %q", got)
+	}
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// DefaultIndexFile is the index file used by CacheHandler.
+const DefaultIndexFile = "index.html"
+
+// HandlerOptions configures how Handler maps request paths to the URIs of entries.
+type HandlerOptions struct {
+
+	// Prefix is removed from the request path before it is mapped to a URI, like with http.StripPrefix.
+	// Requests outside of Prefix are answered with 404 Not Found.
+	// Unlike with http.StripPrefix, redirects and the Content-Location of variants keep the Prefix.
+	Prefix string
+
+	// IndexFile is the name of the entry served for paths ending with a slash, like "index.html", or empty for none.
+	IndexFile string
+
+	// QueryKeys makes the raw query string part of the URI, like "search?q=go".
+	// If false, the query string is ignored.
+	QueryKeys bool
+
+	// Fallback, if not nil, serves requests for the root if the cache has no entry and no rewrite rule for it.
+	Fallback http.Handler
+}
+
+// Handler returns a handler that serves the entries of this cache.
+// The URI of an entry is the cleaned, percent-decoded request path without leading slash, after removing options.Prefix.
+// Requests for paths which are not clean are redirected to the clean path.
+// With an IndexFile, a path ending with a slash is served from the IndexFile entry of that directory,
+// and a path without trailing slash is redirected to the path with trailing slash if only such an index entry exists.
+// A request for an IndexFile itself is redirected to its directory.
+// Requests without entry are served according to the rewrite rules of this cache, see SetRewriteRules,
+// and otherwise answered with 404 Not Found.
+func (c *Cache) Handler(options HandlerOptions) http.Handler {
+	return &handler{cache: c, options: options}
+}
+
+func Handler(options HandlerOptions) http.Handler {
+	return GlobalCache.Handler(options)
+}
+
+// handler serves the entries of a cache.
+type handler struct {
+	cache   *Cache
+	options HandlerOptions
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := h.options.Prefix
+	requestPath := r.URL.Path
+	if !strings.HasPrefix(requestPath, prefix) {
+		h.cache.ServeError(w, r, http.StatusNotFound)
+		return
+	}
+	requestPath = requestPath[len(prefix):]
+	if prefix != "" && !strings.HasSuffix(prefix, "/") && requestPath != "" && !strings.HasPrefix(requestPath, "/") {
+		// The prefix "/static" does not match "/statically".
+		h.cache.ServeError(w, r, http.StatusNotFound)
+		return
+	}
+	requestPath = "/" + strings.TrimPrefix(requestPath, "/")
+	cleaned := path.Clean(requestPath)
+	if strings.HasSuffix(requestPath, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	if cleaned != requestPath {
+		h.redirect(w, r, cleaned)
+		return
+	}
+	if h.rewrite(w, r, cleaned, true) {
+		return
+	}
+	uri := cleaned[1:]
+	index := h.options.IndexFile
+	if index != "" && (uri == index || strings.HasSuffix(uri, "/"+index)) {
+		h.redirect(w, r, strings.TrimSuffix(cleaned, index))
+		return
+	}
+	if entry, ok := h.cache.Get(h.key(r, uri)); ok {
+		entry.serve(w, r, h.cache, h.options.Prefix, true)
+		return
+	}
+	if uri == "" || strings.HasSuffix(uri, "/") {
+		if index != "" {
+			if entry, ok := h.cache.Get(h.key(r, uri+index)); ok {
+				entry.serve(w, r, h.cache, h.options.Prefix, true)
+				return
+			}
+		}
+		if uri != "" && h.exists(r, strings.TrimSuffix(uri, "/")) {
+			h.redirect(w, r, strings.TrimSuffix(cleaned, "/"))
+			return
+		}
+	} else if h.exists(r, uri+"/") || index != "" && h.exists(r, uri+"/"+index) {
+		h.redirect(w, r, cleaned+"/")
+		return
+	}
+	if h.rewrite(w, r, cleaned, false) {
+		return
+	}
+	if uri == "" && h.options.Fallback != nil {
+		h.options.Fallback.ServeHTTP(w, r)
+		return
+	}
+	h.cache.recordMiss(h.key(r, uri))
+	h.cache.serveError(w, r, http.StatusNotFound, h.options.Prefix)
+}
+
+// rewrite applies the first matching rewrite rule of the cache to the request for requestPath, see RewriteRule.
+// Only rules with Force are applied if force is true.
+// It returns false if no rule matches, or if the entry which a matching rule rewrites to does not exist.
+func (h *handler) rewrite(w http.ResponseWriter, r *http.Request, requestPath string, force bool) bool {
+	rule, target, ok := h.cache.rewrite(requestPath, force)
+	if !ok {
+		return false
+	}
+	if rule.isRedirect() {
+		query := ""
+		if i := strings.IndexByte(target, '?'); i >= 0 {
+			target, query = target[:i], target[i:]
+		} else if r.URL.RawQuery != "" {
+			query = "?" + r.URL.RawQuery
+		}
+		if strings.HasPrefix(target, "/") {
+			target = escapePath(strings.TrimSuffix(h.options.Prefix, "/") + target)
+		}
+		http.Redirect(w, r, target+query, rule.Status)
+		return true
+	}
+	uri := strings.TrimPrefix(target, "/")
+	if index := h.options.IndexFile; index != "" && (uri == "" || strings.HasSuffix(uri, "/")) {
+		uri += index
+	}
+	entry, ok := h.cache.Get(h.key(r, uri))
+	if !ok {
+		return false
+	}
+	if rule.Status == http.StatusNotFound {
+		entry.serveWithStatus(w, r, h.cache, h.options.Prefix, http.StatusNotFound)
+	} else {
+		entry.serve(w, r, h.cache, h.options.Prefix, true)
+	}
+	return true
+}
+
+// key returns the key of the entry for uri, which includes the query string of r if QueryKeys is set.
+func (h *handler) key(r *http.Request, uri string) string {
+	if h.options.QueryKeys && r.URL.RawQuery != "" {
+		return uri + "?" + r.URL.RawQuery
+	}
+	return uri
+}
+
+// exists returns true if there is an entry for uri.
+func (h *handler) exists(r *http.Request, uri string) bool {
+	_, ok := h.cache.peek(h.key(r, uri))
+	return ok
+}
+
+// prefixedPath returns the escaped absolute path of uri served under prefix, see HandlerOptions.Prefix.
+func prefixedPath(prefix string, uri string) string {
+	return escapePath(strings.TrimSuffix(prefix, "/") + "/" + uri)
+}
+
+// redirect redirects permanently to the given path within the Prefix, keeping the query string.
+func (h *handler) redirect(w http.ResponseWriter, r *http.Request, to string) {
+	target := escapePath(strings.TrimSuffix(h.options.Prefix, "/") + to)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	status := http.StatusMovedPermanently
+	if !isSafe(r.Method) {
+		status = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, r, target, status)
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	c := New()
+	for _, uri := range []string{"index.html", "docs/index.html", "a b.txt", "file", "search?q=go"} {
+		c.Add(&Entry{URI: uri, Body: []byte(uri), ContentType: "text/plain"})
+	}
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	handler := c.CacheHandler(nil)
+	prefixed := c.Handler(HandlerOptions{Prefix: "/static", IndexFile: DefaultIndexFile, QueryKeys: true})
+	tests := []struct {
+		handler  http.Handler
+		method   string
+		target   string
+		status   int
+		location string
+		body     string
+	}{
+		{handler, http.MethodGet, "/", http.StatusOK, "", "index.html"},
+		{handler, http.MethodGet, "/docs", http.StatusMovedPermanently, "/docs/", ""},
+		{handler, http.MethodGet, "/docs/", http.StatusOK, "", "docs/index.html"},
+		{handler, http.MethodGet, "/docs/index.html?x=1", http.StatusMovedPermanently, "/docs/?x=1", ""},
+		{handler, http.MethodGet, "/index.html", http.StatusMovedPermanently, "/", ""},
+		{handler, http.MethodGet, "/a%20b.txt?ignored", http.StatusOK, "", "a b.txt"},
+		{handler, http.MethodGet, "/x/../file", http.StatusMovedPermanently, "/file", ""},
+		{handler, http.MethodGet, "/file/", http.StatusMovedPermanently, "/file", ""},
+		{handler, http.MethodPost, "/file/", http.StatusPermanentRedirect, "/file", ""},
+		{handler, http.MethodGet, "/missing", http.StatusNotFound, "", ""},
+		{prefixed, http.MethodGet, "/static/search?q=go", http.StatusOK, "", "search?q=go"},
+		{prefixed, http.MethodGet, "/static/search?q=rust", http.StatusNotFound, "", ""},
+		{prefixed, http.MethodGet, "/staticfile", http.StatusNotFound, "", ""},
+		{prefixed, http.MethodGet, "/other/file", http.StatusNotFound, "", ""},
+		{prefixed, http.MethodGet, "/static/docs", http.StatusMovedPermanently, "/static/docs/", ""},
+		{prefixed, http.MethodGet, "/static/a%20b/../file", http.StatusMovedPermanently, "/static/file", ""},
+		{New().Handler(HandlerOptions{Fallback: fallback}), http.MethodGet, "/", http.StatusTeapot, "", ""},
+		{New().Handler(HandlerOptions{Fallback: fallback}), http.MethodGet, "/x", http.StatusNotFound, "", ""},
+	}
+	for _, test := range tests {
+		w := doRequest(test.handler, test.method, test.target)
+		if w.Code != test.status || w.Header().Get(header.Location) != test.location {
+			t.Errorf("%s %s: got %d %q, want %d %q", test.method, test.target, w.Code, w.Header().Get(header.Location), test.status, test.location)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s: got %q, want %q", test.method, test.target, w.Body.String(), test.body)
+		}
+	}
+}
+
+func TestSitemapListsIndexFileDirectories(t *testing.T) {
+	c := New()
+	for _, uri := range []string{"index.html", "docs/index.html", "docs/a.html"} {
+		c.Add(&Entry{URI: uri, Body: []byte("x"), ContentType: "text/html"})
+	}
+	want := "[https://example.com/ https://example.com/docs/ https://example.com/docs/a.html]"
+	if got := fmt.Sprint(sitemapLocs(t, c.Sitemap(httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil)))); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	w := doRequest(c.SitemapHandler(SitemapOptions{IndexFile: DefaultIndexFile}), http.MethodGet, "/sitemap.xml")
+	if got := fmt.Sprint(sitemapLocs(t, w.Body.String())); got != want {
+		t.Errorf("got %s from the sitemap handler, want %s", got, want)
+	}
+
+	hosts := NewHosts()
+	hosts.Add("example.com", c, HandlerOptions{})
+	w = doRequest(hosts.SitemapHandler(SitemapOptions{}), http.MethodGet, "/sitemap.xml")
+	if got := fmt.Sprint(sitemapLocs(t, w.Body.String())); got != "[https://example.com/docs/a.html https://example.com/docs/index.html https://example.com/index.html]" {
+		t.Errorf("got %s from a host without IndexFile", got)
+	}
+	hosts.Add("example.com", c, HandlerOptions{IndexFile: DefaultIndexFile})
+	w = doRequest(hosts.SitemapHandler(SitemapOptions{}), http.MethodGet, "/sitemap.xml")
+	if got := fmt.Sprint(sitemapLocs(t, w.Body.String())); got != want {
+		t.Errorf("got %s from a host with IndexFile, want %s", got, want)
+	}
+}
+
+func TestPrefixedURLs(t *testing.T) {
+	c := New()
+	c.Add(&Entry{URI: "p", Body: []byte("en"), ContentType: "text/html", Language: "en", Variants: []*Entry{
+		{URI: "p.de", Body: []byte("de"), ContentType: "text/html", Language: "de"},
+	}})
+	asset := c.Fingerprint(&Entry{URI: "app.js", Body: []byte("v1"), ContentType: "text/javascript"})
+
+	w := doRequest(c.Handler(HandlerOptions{Prefix: "/static"}), http.MethodGet, "/static/p", header.AcceptLanguage, "de")
+	if w.Body.String() != "de" || w.Header().Get(header.ContentLocation) != "/static/p.de" {
+		t.Errorf("got %q, Content-Location %q", w.Body.String(), w.Header().Get(header.ContentLocation))
+	}
+
+	assetPath := c.FuncMap("/static/")["asset"].(func(string) (string, error))
+	if got, err := assetPath("app.js"); err != nil || got != "/static/"+asset {
+		t.Errorf("got asset %q, %v", got, err)
+	}
+
+	want := "[https://example.com/static/p]"
+	w = doRequest(c.SitemapHandler(SitemapOptions{Prefix: "/static"}), http.MethodGet, "/sitemap.xml")
+	if got := fmt.Sprint(sitemapLocs(t, w.Body.String())); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	hosts := NewHosts()
+	hosts.Add("example.com", c, HandlerOptions{Prefix: "/static"})
+	w = doRequest(hosts.SitemapHandler(SitemapOptions{}), http.MethodGet, "/sitemap.xml")
+	if got := fmt.Sprint(sitemapLocs(t, w.Body.String())); got != want {
+		t.Errorf("got %s from a host with Prefix, want %s", got, want)
+	}
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Hosts maps hostnames to caches, so that one server serves several sites, each from its own cache.
+// A hostname is either exact, like "example.com", or a wildcard for all its subdomains, like "*.example.com".
+// Exact hostnames take precedence over wildcards, and longer wildcards over shorter ones.
+// Hostnames are compared case-insensitively, without port.
+type Hosts struct {
+	mutex sync.RWMutex
+
+	// The sites by hostname.
+	sites map[string]*site
+
+	// The canonical hostnames by alias.
+	aliases map[string]string
+
+	// The hostname of the site serving requests for unknown hosts, or empty.
+	defaultHost string
+}
+
+// site is a host of Hosts.
+type site struct {
+	cache   *Cache
+	options HandlerOptions
+	handler http.Handler
+
+	// The sitemap handlers by SitemapOptions, created on first use.
+	sitemaps map[SitemapOptions]http.Handler
+}
+
+// NewHosts creates a new, empty host registry.
+func NewHosts() *Hosts {
+	return &Hosts{sites: make(map[string]*site), aliases: make(map[string]string)}
+}
+
+// Add adds a site for hostname, which serves the entries of cache with the given options, see Cache.Handler.
+// The Fallback of options serves the root of this host only.
+// If hostname already has a site or is an alias, it is replaced.
+func (h *Hosts) Add(hostname string, cache *Cache, options HandlerOptions) {
+	hostname = normalizeHost(hostname)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.aliases, hostname)
+	h.sites[hostname] = &site{cache: cache, options: options, handler: cache.Handler(options), sitemaps: make(map[SitemapOptions]http.Handler)}
+}
+
+// Alias makes alias, which may be a wildcard, an alternative name of the host canonical.
+// Requests for alias are redirected permanently to the same path and query on canonical, keeping scheme and port.
+// canonical must be an exact hostname added with Add.
+func (h *Hosts) Alias(alias string, canonical string) {
+	alias = normalizeHost(alias)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.sites, alias)
+	h.aliases[alias] = normalizeHost(canonical)
+}
+
+// SetDefault sets the host which serves requests for unknown hosts and requests without Host, or empty for none.
+// Without default host, such requests are answered with 421 Misdirected Request.
+func (h *Hosts) SetDefault(hostname string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.defaultHost = normalizeHost(hostname)
+}
+
+// Cache returns the cache which serves requests for host, which may include a port.
+// For an alias, the cache of its canonical host is returned.
+func (h *Hosts) Cache(host string) (*Cache, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	s, canonical := h.lookup(normalizeHost(host))
+	if s == nil {
+		if s = h.sites[canonical]; s == nil {
+			return nil, false
+		}
+	}
+	return s.cache, true
+}
+
+// Hostnames returns the exact hostnames of the sites and aliases in alphabetical order, without wildcards,
+// for example for https.MustServeHttps, which needs a certificate for each of them.
+func (h *Hosts) Hostnames() []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	hostnames := make([]string, 0, len(h.sites)+len(h.aliases))
+	for hostname := range h.sites {
+		if !strings.HasPrefix(hostname, "*.") {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	for alias := range h.aliases {
+		if !strings.HasPrefix(alias, "*.") {
+			hostnames = append(hostnames, alias)
+		}
+	}
+	sort.Strings(hostnames)
+	return hostnames
+}
+
+// Handler returns a handler that serves each request from the site of its host.
+// Requests for an alias are redirected to the canonical host.
+func (h *Hosts) Handler() http.Handler {
+	return h.dispatch(func(s *site, r *http.Request) http.Handler {
+		return s.handler
+	})
+}
+
+// SitemapHandler returns a handler that serves the sitemaps of the site of each host, see Cache.SitemapHandler.
+// options.BaseURL should be empty, so that the URLs in each sitemap start with the scheme and host of the request.
+// The IndexFile and Prefix of each site are taken from the options with which it was added.
+func (h *Hosts) SitemapHandler(options SitemapOptions) http.Handler {
+	return h.dispatch(func(s *site, r *http.Request) http.Handler {
+		options := options
+		options.IndexFile = s.options.IndexFile
+		options.Prefix = s.options.Prefix
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		handler, ok := s.sitemaps[options]
+		if !ok {
+			handler = s.cache.SitemapHandler(options)
+			s.sitemaps[options] = handler
+		}
+		return handler
+	})
+}
+
+// dispatch returns a handler that serves each request with the handler selected for the site of its host.
+func (h *Hosts) dispatch(selectHandler func(s *site, r *http.Request) http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mutex.RLock()
+		s, canonical := h.lookup(normalizeHost(r.Host))
+		if s == nil && canonical == "" {
+			s = h.sites[h.defaultHost]
+		}
+		h.mutex.RUnlock()
+		switch {
+		case s != nil:
+			selectHandler(s, r).ServeHTTP(w, r)
+		case canonical != "":
+			h.redirect(w, r, canonical)
+		default:
+			http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+		}
+	})
+}
+
+// lookup returns the site for hostname, or if hostname is an alias, the canonical hostname.
+// It must be called with the mutex locked.
+func (h *Hosts) lookup(hostname string) (*site, string) {
+	if hostname == "" {
+		return nil, ""
+	}
+	for name := hostname; ; {
+		if s, ok := h.sites[name]; ok {
+			return s, ""
+		}
+		if canonical, ok := h.aliases[name]; ok {
+			return nil, canonical
+		}
+		label := strings.TrimPrefix(name, "*.")
+		dot := strings.IndexByte(label, '.')
+		if dot < 0 {
+			return nil, ""
+		}
+		name = "*" + label[dot:]
+	}
+}
+
+// redirect redirects permanently to the same path and query on the canonical host, keeping the scheme and port of r.
+func (h *Hosts) redirect(w http.ResponseWriter, r *http.Request, canonical string) {
+	host := canonical
+	if _, port, err := net.SplitHostPort(r.Host); err == nil {
+		host = net.JoinHostPort(canonical, port)
+	}
+	status := http.StatusMovedPermanently
+	if !isSafe(r.Method) {
+		status = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, r, requestScheme(r)+"://"+host+r.URL.RequestURI(), status)
+}
+
+// normalizeHost returns the hostname of host without port, brackets and trailing dot, in lower case.
+func normalizeHost(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
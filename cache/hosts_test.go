@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestHosts() (*Hosts, *Cache, *Cache) {
+	a, b := New(), New()
+	a.Add(&Entry{URI: "index.html", Body: []byte("A"), ContentType: "text/html"})
+	b.Add(&Entry{URI: "index.html", Body: []byte("B"), ContentType: "text/html"})
+	hosts := NewHosts()
+	hosts.Add("Example.com", a, HandlerOptions{IndexFile: DefaultIndexFile})
+	hosts.Add("*.example.org", b, HandlerOptions{IndexFile: DefaultIndexFile})
+	hosts.Add("x.example.org", a, HandlerOptions{IndexFile: DefaultIndexFile})
+	hosts.Alias("www.example.com", "example.com")
+	hosts.Alias("*.example.net", "example.com")
+	return hosts, a, b
+}
+
+func TestHosts(t *testing.T) {
+	hosts, _, _ := newTestHosts()
+	tests := []struct {
+		method   string
+		target   string
+		status   int
+		location string
+		body     string
+	}{
+		{http.MethodGet, "http://example.com/", http.StatusOK, "", "A"},
+		{http.MethodGet, "http://EXAMPLE.com.:8080/", http.StatusOK, "", "A"},
+		{http.MethodGet, "http://y.example.org/", http.StatusOK, "", "B"},
+		{http.MethodGet, "http://z.y.example.org/", http.StatusOK, "", "B"},
+		{http.MethodGet, "http://x.example.org/", http.StatusOK, "", "A"},
+		{http.MethodGet, "http://www.example.com:8443/a?q=1", http.StatusMovedPermanently, "http://example.com:8443/a?q=1", ""},
+		{http.MethodPost, "http://www.example.com/", http.StatusPermanentRedirect, "http://example.com/", ""},
+		{http.MethodGet, "https://q.example.net/", http.StatusMovedPermanently, "https://example.com/", ""},
+		{http.MethodGet, "http://other/", http.StatusMisdirectedRequest, "", ""},
+	}
+	for _, test := range tests {
+		w := doRequest(hosts.Handler(), test.method, test.target)
+		if w.Code != test.status || w.Header().Get(header.Location) != test.location {
+			t.Errorf("%s %s: got %d %q, want %d %q", test.method, test.target, w.Code, w.Header().Get(header.Location), test.status, test.location)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s: got %q, want %q", test.method, test.target, w.Body.String(), test.body)
+		}
+	}
+}
+
+func TestHostsDefault(t *testing.T) {
+	hosts, _, _ := newTestHosts()
+	hosts.SetDefault("example.com")
+	if w := doRequest(hosts.Handler(), http.MethodGet, "http://other/"); w.Code != http.StatusOK || w.Body.String() != "A" {
+		t.Errorf("got %d %q for an unknown host", w.Code, w.Body.String())
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Host = ""
+	w := httptest.NewRecorder()
+	hosts.Handler().ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "A" {
+		t.Errorf("got %d %q for a request without Host", w.Code, w.Body.String())
+	}
+}
+
+func TestHostsCache(t *testing.T) {
+	hosts, a, b := newTestHosts()
+	tests := []struct {
+		host  string
+		cache *Cache
+	}{
+		{"example.com:443", a},
+		{"www.example.com", a},
+		{"q.example.net", a},
+		{"y.example.org", b},
+		{"other", nil},
+	}
+	for _, test := range tests {
+		if got, ok := hosts.Cache(test.host); got != test.cache || ok != (test.cache != nil) {
+			t.Errorf("%s: got %p, %v", test.host, got, ok)
+		}
+	}
+	if got := fmt.Sprint(hosts.Hostnames()); got != "[example.com www.example.com x.example.org]" {
+		t.Errorf("got hostnames %s", got)
+	}
+}
+
+func TestHostsSitemapHandler(t *testing.T) {
+	hosts, _, _ := newTestHosts()
+	w := doRequest(hosts.SitemapHandler(SitemapOptions{}), http.MethodGet, "http://y.example.org/sitemap.xml")
+	if got := fmt.Sprint(sitemapLocs(t, w.Body.String())); got != "[https://y.example.org/]" {
+		t.Errorf("got %s", got)
+	}
+}
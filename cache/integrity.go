@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SHA256 is the constant for the Subresource Integrity hash algorithm "sha256".
+	SHA256 = "sha256"
+
+	// SHA384 is the constant for the Subresource Integrity hash algorithm "sha384", recommended by Subresource Integrity.
+	SHA384 = "sha384"
+
+	// SHA512 is the constant for the Subresource Integrity hash algorithm "sha512".
+	SHA512 = "sha512"
+
+	// The header fields for integrity digests of RFC 9530.
+	contentDigest = "Content-Digest"
+	reprDigest    = "Repr-Digest"
+)
+
+// computeIntegrity returns the Subresource Integrity values of body by hash algorithm.
+func computeIntegrity(body []byte) map[string]string {
+	sum256 := sha256.Sum256(body)
+	sum384 := sha512.Sum384(body)
+	sum512 := sha512.Sum512(body)
+	return map[string]string{
+		SHA256: SHA256 + "-" + base64.StdEncoding.EncodeToString(sum256[:]),
+		SHA384: SHA384 + "-" + base64.StdEncoding.EncodeToString(sum384[:]),
+		SHA512: SHA512 + "-" + base64.StdEncoding.EncodeToString(sum512[:]),
+	}
+}
+
+// computeDigests returns the SHA-256 digests of the representations of this entry by content coding.
+func (e *Entry) computeDigests() map[string][]byte {
+	digests := make(map[string][]byte)
+	for _, enc := range e.encodings() {
+		sum := sha256.Sum256(enc.body)
+		digests[enc.coding] = sum[:]
+	}
+	return digests
+}
+
+// Integrity returns the Subresource Integrity value for the entry with the given URI or logical asset name,
+// computed with the given hash algorithm, like "sha384-…".
+// A leading slash of uri is ignored.
+func (c *Cache) Integrity(uri string, algorithm string) (string, bool) {
+	if fingerprinted, ok := c.AssetURI(uri); ok {
+		uri = fingerprinted
+	}
+	entry, ok := c.peek(strings.TrimPrefix(uri, "/"))
+	if !ok {
+		return "", false
+	}
+	value, ok := entry.Integrity[algorithm]
+	return value, ok
+}
+
+func Integrity(uri string, algorithm string) (string, bool) {
+	return GlobalCache.Integrity(uri, algorithm)
+}
+
+// SetDigests sets whether responses of this cache carry the integrity digests Repr-Digest and Content-Digest of RFC 9530.
+// The digests are computed with SHA-256 when an entry is added.
+// The obsolete Digest header field of RFC 3230 is not supported.
+func (c *Cache) SetDigests(enabled bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.digests = enabled
+}
+
+func SetDigests(enabled bool) {
+	GlobalCache.SetDigests(enabled)
+}
+
+// sendsDigests returns true if responses of this cache carry integrity digests.
+func (c *Cache) sendsDigests() bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.digests
+}
+
+// writeDigests sets the integrity digest header fields for the representation of this entry with the given content coding.
+// Content-Digest is only set if the response content is the whole representation, and not a range.
+func (e *Entry) writeDigests(w http.ResponseWriter, coding string, whole bool) {
+	value := "sha-256=:" + base64.StdEncoding.EncodeToString(e.digests[coding]) + ":"
+	w.Header().Set(reprDigest, value)
+	if whole {
+		w.Header().Set(contentDigest, value)
+	}
+}
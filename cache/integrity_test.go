@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"testing"
+)
+
+func TestComputeIntegrity(t *testing.T) {
+	integrity := computeIntegrity([]byte("alert(1)"))
+	tests := []struct {
+		algorithm string
+		want      string
+	}{
+		{SHA256, "sha256-bhHHL3z2vDgxUt0W3dWQOrprscmda2Y5pLsLg4GF+pI="},
+		{SHA384, "sha384-HT2E9NfWiuQ/w1PRai+hTyqW16NIoCGA/m8VQDUopfAtcz6YQjtsMmQd5uRbVDpW"},
+	}
+	for _, test := range tests {
+		if got := integrity[test.algorithm]; got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+	if got := integrity[SHA512]; len(got) != len("sha512-")+88 {
+		t.Errorf("got %q for sha512", got)
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	c := New()
+	uri := c.Fingerprint(&Entry{URI: "app.js", Body: []byte("alert(1)"), ContentType: "text/javascript"})
+	want := "sha384-HT2E9NfWiuQ/w1PRai+hTyqW16NIoCGA/m8VQDUopfAtcz6YQjtsMmQd5uRbVDpW"
+	for _, name := range []string{"app.js", "/app.js", uri, "/" + uri} {
+		if got, ok := c.Integrity(name, SHA384); !ok || got != want {
+			t.Errorf("%s: got %q, %v", name, got, ok)
+		}
+	}
+	if _, ok := c.Integrity("app.js", "md5"); ok {
+		t.Error("got a value for an unsupported algorithm")
+	}
+	if _, ok := c.Integrity("missing.js", SHA384); ok {
+		t.Error("got a value for a missing entry")
+	}
+}
+
+func TestDigests(t *testing.T) {
+	c := New()
+	c.Add(&Entry{URI: "app.js", Body: []byte("alert(1)"), ContentType: "text/javascript"})
+	if w := doRequest(c.CacheHandler(nil), http.MethodGet, "/app.js"); w.Header().Get(reprDigest) != "" || w.Header().Get(contentDigest) != "" {
+		t.Errorf("got digests %v without SetDigests", w.Header())
+	}
+
+	c.SetDigests(true)
+	identity := "sha-256=:bhHHL3z2vDgxUt0W3dWQOrprscmda2Y5pLsLg4GF+pI=:"
+	w := doRequest(c.CacheHandler(nil), http.MethodGet, "/app.js")
+	if w.Header().Get(reprDigest) != identity || w.Header().Get(contentDigest) != identity {
+		t.Errorf("got Repr-Digest %q, Content-Digest %q", w.Header().Get(reprDigest), w.Header().Get(contentDigest))
+	}
+
+	w = doRequest(c.CacheHandler(nil), http.MethodGet, "/app.js", header.Range, "bytes=0-1")
+	if w.Code != http.StatusPartialContent || w.Header().Get(reprDigest) != identity || w.Header().Get(contentDigest) != "" {
+		t.Errorf("got %d, Repr-Digest %q, Content-Digest %q for a range", w.Code, w.Header().Get(reprDigest), w.Header().Get(contentDigest))
+	}
+
+	w = doRequest(c.CacheHandler(nil), http.MethodGet, "/app.js", header.AcceptEncoding, "gzip")
+	sum := sha256.Sum256(w.Body.Bytes())
+	gzip := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	if w.Header().Get(header.ContentEncoding) != "gzip" || gzip == identity {
+		t.Fatalf("got Content-Encoding %q", w.Header().Get(header.ContentEncoding))
+	}
+	if w.Header().Get(reprDigest) != gzip || w.Header().Get(contentDigest) != gzip {
+		t.Errorf("got Repr-Digest %q, Content-Digest %q, want %q", w.Header().Get(reprDigest), w.Header().Get(contentDigest), gzip)
+	}
+}
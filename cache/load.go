@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/nelkinda/http-go/mimetype"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LoadOptions configures how LoadDir and LoadFS map files to entries.
+type LoadOptions struct {
+
+	// Prefix is prepended to the slash-separated relative path of each file to form the URI of its entry.
+	Prefix string
+
+	// MaxAge is the maximum cache age of files not matched by any of the MaxAgeRules.
+	MaxAge time.Duration
+
+	// MaxAgeRules override MaxAge for files whose relative path matches.
+	// The first matching rule wins.
+	MaxAgeRules []MaxAgeRule
+
+	// ContentTypes overrides or extends the content types of the mimetype package by file name extension, like ".html".
+	ContentTypes map[string]string
+
+	// IncludeHidden includes files and directories whose name starts with a dot.
+	// The directory .well-known at the root, see RFC 8615, is always included.
+	IncludeHidden bool
+
+	// Fingerprint, if not nil, fingerprints the files whose relative path matches, see Cache.Fingerprint.
+	Fingerprint *regexp.Regexp
+
+	// FileThreshold, if greater than 0, makes files of at least this size file-backed entries, see NewFileEntry.
+	// It only applies to LoadDir, as only the files of the operating system can be served from disk.
+	FileThreshold int64
+
+	// Mmap memory-maps the files of file-backed entries on Linux, see NewFileEntry.
+	// It is off by default, because truncating a memory-mapped file crashes the process; only set it for files which are never modified in place.
+	Mmap bool
+}
+
+// MaxAgeRule sets the maximum cache age of all files whose relative path matches Pattern.
+// Example: hashed assets, which never change, can be cached for a year:
+//
+//	MaxAgeRule{Pattern: regexp.MustCompile(`\.[0-9a-f]{8,}\.(css|js)$`), MaxAge: 365 * 24 * time.Hour}
+type MaxAgeRule struct {
+	Pattern *regexp.Regexp
+	MaxAge  time.Duration
+}
+
+// LoadSummary reports what LoadDir or LoadFS loaded.
+type LoadSummary struct {
+
+	// The URIs of the loaded entries, in the order of loading.
+	URIs []string
+
+	// The total size of the uncompressed bodies.
+	Bytes int64
+
+	// The files which were loaded as ApplicationOctetStream because their content type is unknown.
+	UnknownContentTypes []string
+}
+
+// String returns a short human-readable description of this summary, suitable for logging.
+func (s LoadSummary) String() string {
+	return fmt.Sprintf("loaded %d files, %d bytes, %d with unknown content type", len(s.URIs), s.Bytes, len(s.UnknownContentTypes))
+}
+
+// LoadDir loads all files in the directory tree rooted at dir.
+// The directory is remembered, so that Reload and Watch pick up files which are added to or removed from it later.
+// See LoadFS for details.
+func (c *Cache) LoadDir(dir string, options LoadOptions) (LoadSummary, error) {
+	c.mutex.Lock()
+	if c.dirs == nil {
+		c.dirs = make(map[string]LoadOptions)
+	}
+	c.dirs[dir] = options
+	c.mutex.Unlock()
+	return c.loadFS(os.DirFS(dir), dir, options)
+}
+
+func LoadDir(dir string, options LoadOptions) (LoadSummary, error) {
+	return GlobalCache.LoadDir(dir, options)
+}
+
+// LoadFS loads all files in fsys, for example an embed.FS.
+// The URI of each entry is options.Prefix followed by the slash-separated path of the file relative to the root of fsys.
+// The content type is determined from the file name extension.
+// If loading a file fails, the files loaded so far remain in the cache, and the error is returned.
+func (c *Cache) LoadFS(fsys fs.FS, options LoadOptions) (LoadSummary, error) {
+	return c.loadFS(fsys, "", options)
+}
+
+func LoadFS(fsys fs.FS, options LoadOptions) (LoadSummary, error) {
+	return GlobalCache.LoadFS(fsys, options)
+}
+
+// loadFS loads all files in fsys.
+// If fsys represents the directory dir of the operating system, the entries remember their filename.
+func (c *Cache) loadFS(fsys fs.FS, dir string, options LoadOptions) (LoadSummary, error) {
+	var summary LoadSummary
+	err := options.walk(fsys, func(name string, d fs.DirEntry) error {
+		entry, err := options.loadEntry(fsys, dir, name, d)
+		if err != nil {
+			return err
+		}
+		if entry.ContentType == "" {
+			entry.ContentType = mimetype.ApplicationOctetStream
+			summary.UnknownContentTypes = append(summary.UnknownContentTypes, name)
+		}
+		summary.URIs = append(summary.URIs, c.addLoaded(entry, name, options))
+		summary.Bytes += entry.bodySize()
+		return nil
+	})
+	return summary, err
+}
+
+// addLoaded adds the entry loaded from the file name, fingerprinted if the options say so, and returns its URI.
+func (c *Cache) addLoaded(entry *Entry, name string, options LoadOptions) string {
+	if options.Fingerprint != nil && options.Fingerprint.MatchString(name) {
+		return c.Fingerprint(entry)
+	}
+	c.Add(entry)
+	return entry.URI
+}
+
+// isLoaded returns true if the entry for the given URI exists, either directly or fingerprinted.
+func (c *Cache) isLoaded(uri string) bool {
+	if _, ok := c.peek(uri); ok {
+		return true
+	}
+	_, ok := c.AssetURI(uri)
+	return ok
+}
+
+// The directory for well-known URIs as per RFC 8615.
+const wellKnown = ".well-known"
+
+// walk calls f for all regular files in fsys which are not excluded by these options.
+func (o *LoadOptions) walk(fsys fs.FS, f func(name string, d fs.DirEntry) error) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && name != wellKnown && !o.IncludeHidden && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return f(name, d)
+	})
+}
+
+// loadEntry reads the file name from fsys and creates its entry.
+// If dir is not empty, it is the directory of the operating system represented by fsys.
+func (o *LoadOptions) loadEntry(fsys fs.FS, dir string, name string, d fs.DirEntry) (*Entry, error) {
+	info, err := d.Info()
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" && o.FileThreshold > 0 && info.Size() >= o.FileThreshold {
+		entry, err := NewFileEntry(filepath.Join(dir, filepath.FromSlash(name)), o.Prefix+name, o.contentType(name), o.Mmap)
+		if err != nil {
+			return nil, err
+		}
+		entry.MaxAge = o.maxAge(name)
+		return entry, nil
+	}
+	body, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{
+		URI:         o.Prefix + name,
+		Body:        body,
+		ContentType: o.contentType(name),
+		MaxAge:      o.maxAge(name),
+	}
+	if dir != "" {
+		entry.Filename = filepath.Join(dir, filepath.FromSlash(name))
+	}
+	if modTime := info.ModTime().UTC(); !modTime.IsZero() {
+		// embed.FS has no modification times.
+		entry.LastModified = &modTime
+	}
+	return entry, nil
+}
+
+// contentType returns the content type for the file name, or "" if it is unknown.
+func (o *LoadOptions) contentType(name string) string {
+	ext := path.Ext(name)
+	if contentType, ok := o.ContentTypes[ext]; ok {
+		return contentType
+	}
+	return mimetype.ByExtension(ext)
+}
+
+// maxAge returns the maximum cache age for the file name.
+func (o *LoadOptions) maxAge(name string) time.Duration {
+	for _, rule := range o.MaxAgeRules {
+		if rule.Pattern.MatchString(name) {
+			return rule.MaxAge
+		}
+	}
+	return o.MaxAge
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":          {Data: []byte("<html></html>")},
+		"css/site.css":        {Data: []byte("body {}")},
+		"js/app.0123abcd.js":  {Data: []byte("app()")},
+		"data/blob.unknown":   {Data: []byte{0, 1, 2}},
+		"data/table.custom":   {Data: []byte("a,b")},
+		".hidden/secret.txt":  {Data: []byte("secret")},
+		"css/.well-known/x":   {Data: []byte("x")},
+		"css/.editorconfig":   {Data: []byte("root = true")},
+		"fonts/font.woff2":    {Data: []byte("font")},
+		"fonts/sub/empty.txt": {Data: []byte{}},
+	}
+	c := New()
+	summary, err := c.LoadFS(fsys, LoadOptions{
+		Prefix:       "static/",
+		MaxAge:       time.Minute,
+		MaxAgeRules:  []MaxAgeRule{{Pattern: regexp.MustCompile(`\.[0-9a-f]{8,}\.js$`), MaxAge: time.Hour}},
+		ContentTypes: map[string]string{".custom": mimetype.TextCsv},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(summary.URIs); got != "[static/css/site.css static/data/blob.unknown static/data/table.custom static/fonts/font.woff2 static/fonts/sub/empty.txt static/index.html static/js/app.0123abcd.js]" {
+		t.Errorf("got URIs %s", got)
+	}
+	if fmt.Sprint(summary.UnknownContentTypes) != "[data/blob.unknown]" {
+		t.Errorf("got unknown content types %v", summary.UnknownContentTypes)
+	}
+	if summary.Bytes != 35 {
+		t.Errorf("got %d bytes, want 35", summary.Bytes)
+	}
+	tests := []struct {
+		uri         string
+		contentType string
+		maxAge      time.Duration
+	}{
+		{"static/index.html", mimetype.TextHtml, time.Minute},
+		{"static/css/site.css", mimetype.TextCss, time.Minute},
+		{"static/js/app.0123abcd.js", mimetype.TextJavascript, time.Hour},
+		{"static/data/blob.unknown", mimetype.ApplicationOctetStream, time.Minute},
+		{"static/data/table.custom", mimetype.TextCsv, time.Minute},
+	}
+	for _, test := range tests {
+		entry, ok := c.Get(test.uri)
+		if !ok {
+			t.Errorf("%s was not loaded", test.uri)
+			continue
+		}
+		if entry.ContentType != test.contentType || entry.MaxAge != test.maxAge {
+			t.Errorf("%s: got %s, %v, want %s, %v", test.uri, entry.ContentType, entry.MaxAge, test.contentType, test.maxAge)
+		}
+		if entry.Filename != "" {
+			t.Errorf("%s: got Filename %q for a file of an fs.FS", test.uri, entry.Filename)
+		}
+	}
+}
+
+func TestLoadWellKnown(t *testing.T) {
+	fsys := fstest.MapFS{
+		".well-known/security.txt": {Data: []byte("Contact: mailto:security@example.com\n")},
+		".well-known/.secret":      {Data: []byte("secret")},
+		"index.html":               {Data: []byte("<html></html>")},
+	}
+	c := New()
+	summary, err := c.LoadFS(fsys, LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(summary.URIs); got != "[.well-known/security.txt index.html]" {
+		t.Errorf("got URIs %s", got)
+	}
+	if w := doRequest(c.CacheHandler(nil), http.MethodGet, "/.well-known/security.txt"); w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get(header.ContentType), mimetype.TextPlain) {
+		t.Errorf("got %d, Content-Type %q", w.Code, w.Header().Get(header.ContentType))
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	writeFile(t, dir, "a.txt", "a", modTime)
+	writeFile(t, dir, "big.bin", "0123456789", modTime)
+	c := New()
+	if _, err := c.LoadDir(dir, LoadOptions{FileThreshold: 10}); err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := c.Get("a.txt")
+	if !ok || entry.Filename != filepath.Join(dir, "a.txt") || !entry.LastModified.Equal(modTime) || entry.IsFileBacked() {
+		t.Errorf("got %v for a.txt", entry)
+	}
+	if entry, ok := c.Get("big.bin"); !ok || !entry.IsFileBacked() {
+		t.Error("big.bin is not file-backed")
+	}
+}
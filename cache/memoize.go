@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoizeOptions configures Memoize.
+type MemoizeOptions struct {
+
+	// TTL is the time for which a captured response is served from the cache, or 0 for as long as it is in the cache.
+	TTL time.Duration
+
+	// Query makes the raw query string part of the cache key.
+	Query bool
+
+	// Headers are the names of request header fields whose values are part of the cache key, like "Accept-Language".
+	// They are sent in the Vary header field.
+	Headers []string
+
+	// Key, if not nil, returns the cache key for a request instead of the key made of the path, Query and Headers.
+	// If Key returns the empty string, the request bypasses the cache.
+	Key func(r *http.Request) string
+}
+
+// Memoize returns a handler which serves GET and HEAD requests from this cache, and fills the cache from next.
+// On a miss, the response of next is captured, and if it is a cacheable 200 OK response, added to the cache,
+// compressed once, and served like any other entry.
+// Concurrent misses for the same key are collapsed into a single request to next.
+// Responses with Set-Cookie, Cache-Control no-store or private, or a Content-Encoding are not cached.
+// The request to next has no conditional, range and Accept-Encoding header fields, so that next produces a complete, uncompressed response.
+// The cache keys start with a slash, so they never collide with the URIs served by CacheHandler.
+func (c *Cache) Memoize(next http.Handler, options MemoizeOptions) http.Handler {
+	return &memoizer{cache: c, next: next, options: options, calls: make(map[string]*memoizeCall)}
+}
+
+func Memoize(next http.Handler, options MemoizeOptions) http.Handler {
+	return GlobalCache.Memoize(next, options)
+}
+
+// memoizer serves memoized responses of a handler.
+type memoizer struct {
+	cache   *Cache
+	next    http.Handler
+	options MemoizeOptions
+	mutex   sync.Mutex
+	calls   map[string]*memoizeCall
+}
+
+// memoizeCall is a request to the next handler in progress, on which concurrent requests for the same key wait.
+type memoizeCall struct {
+	done  chan struct{}
+	entry *Entry
+}
+
+func (m *memoizer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	key := m.key(r)
+	if key == "" {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	if entry, ok := m.cache.Get(key); ok && m.isFresh(entry) {
+		entry.serve(w, r, m.cache, "", true)
+		return
+	}
+	m.mutex.Lock()
+	if call, ok := m.calls[key]; ok {
+		m.mutex.Unlock()
+		<-call.done
+		if call.entry != nil {
+			call.entry.serve(w, r, m.cache, "", false)
+		} else {
+			// The response was not cacheable, so it may be specific to the other request.
+			m.next.ServeHTTP(w, r)
+		}
+		return
+	}
+	call := &memoizeCall{done: make(chan struct{})}
+	m.calls[key] = call
+	m.mutex.Unlock()
+
+	response := m.fill(key, call, r)
+	if call.entry != nil {
+		call.entry.serve(w, r, m.cache, "", false)
+	} else {
+		response.writeTo(w)
+	}
+}
+
+// fill calls the next handler for r and stores its response under key, and then releases the requests waiting on call.
+func (m *memoizer) fill(key string, call *memoizeCall, r *http.Request) *capture {
+	defer func() {
+		m.mutex.Lock()
+		delete(m.calls, key)
+		m.mutex.Unlock()
+		close(call.done)
+	}()
+	response := m.capture(r)
+	call.entry = m.store(key, response)
+	return response
+}
+
+// key returns the cache key for r.
+func (m *memoizer) key(r *http.Request) string {
+	if m.options.Key != nil {
+		return m.options.Key(r)
+	}
+	key := r.URL.Path
+	if m.options.Query && r.URL.RawQuery != "" {
+		key += "?" + r.URL.RawQuery
+	}
+	return varyKey(key, m.options.Headers, r)
+}
+
+// isFresh returns true if entry may be served without calling the next handler.
+func (m *memoizer) isFresh(entry *Entry) bool {
+	return entry.freshness != nil && (m.options.TTL <= 0 || entry.freshness.isFresh(time.Now()))
+}
+
+// capture calls the next handler with a GET request for the complete, uncompressed response, and returns the captured response.
+func (m *memoizer) capture(r *http.Request) *capture {
+	out := r.Clone(r.Context())
+	out.Method = http.MethodGet
+	out.Header.Del(header.AcceptEncoding)
+	for _, name := range conditionalHeaders {
+		out.Header.Del(name)
+	}
+	response := newCapture()
+	m.next.ServeHTTP(response, out)
+	response.WriteHeader(http.StatusOK)
+	return response
+}
+
+// store adds the captured response under key if it is cacheable, and returns its entry, or nil.
+func (m *memoizer) store(key string, response *capture) *Entry {
+	if !response.isMemoizable() {
+		return nil
+	}
+	entry := response.entry(key)
+	entry.freshness = &freshness{born: time.Now(), lifetime: m.options.TTL, upstream: response.header}
+	if len(m.options.Headers) > 0 {
+		entry.Header.Add(header.Vary, strings.Join(m.options.Headers, ", "))
+	}
+	m.cache.Add(entry)
+	return entry
+}
+
+// isMemoizable returns true if the captured response may be stored by Memoize.
+func (c *capture) isMemoizable() bool {
+	if c.status != http.StatusOK || c.header.Get(header.SetCookie) != "" {
+		return false
+	}
+	if encoding := c.header.Get(header.ContentEncoding); encoding != "" && encoding != Identity {
+		return false
+	}
+	directives := parseCacheControl(c.header)
+	_, noStore := directives["no-store"]
+	_, private := directives["private"]
+	return !noStore && !private
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"bytes"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler returns a handler which responds with body after delay, and the counter of its calls.
+func countingHandler(body string, delay time.Duration) (http.Handler, *int64) {
+	var calls int64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		time.Sleep(delay)
+		w.Header().Set(header.ContentType, "text/plain")
+		if r.URL.Path == "/cookie" {
+			w.Header().Set(header.SetCookie, "a=b")
+		}
+		_, _ = w.Write([]byte(body + r.URL.RawQuery + r.Header.Get(header.AcceptLanguage)))
+	}), &calls
+}
+
+func TestMemoizeCollapsesConcurrentMisses(t *testing.T) {
+	next, calls := countingHandler("hello", 50*time.Millisecond)
+	memoized := New().Memoize(next, MemoizeOptions{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := doRequest(memoized, http.MethodGet, "/x", header.AcceptEncoding, "gzip"); w.Code != http.StatusOK {
+				t.Errorf("got status %d", w.Code)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := atomic.LoadInt64(calls); got != 1 {
+		t.Errorf("got %d calls for 10 concurrent misses", got)
+	}
+}
+
+func TestMemoizeKeys(t *testing.T) {
+	next, calls := countingHandler("hello ", 0)
+	c := New()
+	memoized := c.Memoize(next, MemoizeOptions{TTL: time.Hour, Query: true, Headers: []string{header.AcceptLanguage}})
+	tests := []struct {
+		target string
+		fields []string
+		body   string
+		calls  int64
+	}{
+		{"/x?q=1", nil, "hello q=1", 1},
+		{"/x?q=1", nil, "hello q=1", 1},
+		{"/x?q=2", nil, "hello q=2", 2},
+		{"/x?q=1", []string{header.AcceptLanguage, "de"}, "hello q=1de", 3},
+		{"/cookie", nil, "hello ", 4},
+		{"/cookie", nil, "hello ", 5},
+	}
+	for _, test := range tests {
+		w := doRequest(memoized, http.MethodGet, test.target, test.fields...)
+		if w.Body.String() != test.body || atomic.LoadInt64(calls) != test.calls {
+			t.Errorf("%s %v: got %q after %d calls, want %q after %d", test.target, test.fields, w.Body.String(), atomic.LoadInt64(calls), test.body, test.calls)
+		}
+	}
+	w := doRequest(memoized, http.MethodGet, "/x?q=1")
+	if got := strings.Join(w.Header().Values(header.Vary), ", "); got != "Accept-Language, Accept-Encoding" {
+		t.Errorf("got Vary %q", got)
+	}
+	if entries, _ := c.Size(); entries != 3 {
+		t.Errorf("got %d entries, want 3", entries)
+	}
+}
+
+func TestMemoizeTTL(t *testing.T) {
+	next, calls := countingHandler("hello", 0)
+	memoized := New().Memoize(next, MemoizeOptions{TTL: 20 * time.Millisecond})
+	doRequest(memoized, http.MethodGet, "/x")
+	doRequest(memoized, http.MethodGet, "/x")
+	time.Sleep(30 * time.Millisecond)
+	doRequest(memoized, http.MethodGet, "/x")
+	if got := atomic.LoadInt64(calls); got != 2 {
+		t.Errorf("got %d calls, want 2", got)
+	}
+}
+
+func TestMemoizedEntriesAreNotPublished(t *testing.T) {
+	next, _ := countingHandler("<p>hello</p>", 0)
+	c := New()
+	c.Add(&Entry{URI: "page.html", Body: []byte("x"), ContentType: "text/html"})
+	doRequest(c.Memoize(next, MemoizeOptions{}), http.MethodGet, "/memoized.html")
+	if entries, _ := c.Size(); entries != 2 {
+		t.Fatalf("got %d entries, want 2", entries)
+	}
+	if w := doRequest(c.SitemapHandler(SitemapOptions{}), http.MethodGet, "/sitemap.xml"); len(sitemapLocs(t, w.Body.String())) != 1 {
+		t.Errorf("got sitemap %s", w.Body.String())
+	}
+	var archive bytes.Buffer
+	if err := c.WriteArchive(&archive, ArchiveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	loaded := New()
+	if _, err := loaded.LoadArchive(&archive); err != nil {
+		t.Fatal(err)
+	}
+	if got := cachedURIs(loaded); got != "page.html" {
+		t.Errorf("got archived entries %q", got)
+	}
+}
+
+func TestMemoizeSniffsContentType(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html><body>hi"))
+	})
+	w := doRequest(New().Memoize(next, MemoizeOptions{}), http.MethodGet, "/x")
+	if got := w.Header().Get(header.ContentType); got != "text/html; charset=utf-8" {
+		t.Errorf("got Content-Type %q", got)
+	}
+}
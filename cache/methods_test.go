@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"testing"
+)
+
+func TestMethods(t *testing.T) {
+	tests := []struct {
+		name    string
+		methods []string
+		method  string
+		status  int
+		allow   string
+		body    string
+	}{
+		{"GET", nil, http.MethodGet, http.StatusOK, "", "hello"},
+		{"HEAD", nil, http.MethodHead, http.StatusOK, "", ""},
+		{"OPTIONS", nil, http.MethodOptions, http.StatusNoContent, "GET, HEAD, OPTIONS", ""},
+		{"POST not allowed", nil, http.MethodPost, http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", ""},
+		{"POST allowed", []string{http.MethodGet, http.MethodPost}, http.MethodPost, http.StatusOK, "", "hello"},
+		{"OPTIONS with Methods", []string{http.MethodGet, http.MethodPost, http.MethodOptions}, http.MethodOptions, http.StatusNoContent, "GET, HEAD, POST, OPTIONS", ""},
+		{"HEAD without GET", []string{http.MethodPost}, http.MethodHead, http.StatusMethodNotAllowed, "POST, OPTIONS", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &Entry{URI: "x", Body: []byte("hello"), ContentType: "text/plain", Methods: test.methods}
+			entry.prepare()
+			w := doRequest(http.HandlerFunc(entry.Serve), test.method, "/x")
+			if w.Code != test.status || w.Body.String() != test.body {
+				t.Errorf("got %d %q, want %d %q", w.Code, w.Body.String(), test.status, test.body)
+			}
+			if got := w.Header().Get(header.Allow); got != test.allow {
+				t.Errorf("got Allow %q, want %q", got, test.allow)
+			}
+		})
+	}
+}
+
+func TestHeadHasContentLength(t *testing.T) {
+	entry := &Entry{URI: "x", Body: []byte("hello"), ContentType: "text/plain"}
+	entry.prepare()
+	w := doRequest(http.HandlerFunc(entry.Serve), http.MethodHead, "/x")
+	if got := w.Header().Get(header.ContentLength); got != "5" {
+		t.Errorf("got Content-Length %q for HEAD, want 5", got)
+	}
+}
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Recorder receives the events of a cache, for example to bridge them to a metrics system like OpenTelemetry.
+// A Recorder must be safe for concurrent use, and should return quickly, as it is called while requests are served.
+type Recorder interface {
+
+	// Hit is called when entry is served from the cache.
+	// Error entries, see SetErrorEntry, and responses which a Proxy or Memoize just fetched are not hits.
+	Hit(entry *Entry)
+
+	// Miss is called when ServeCacheEntry finds no entry for uri.
+	Miss(uri string)
+
+	// NotModified is called when entry is served from the cache as 304 Not Modified.
+	NotModified(entry *Entry)
+
+	// Served is called when bytes of the body of entry were sent with the given content coding for a hit.
+	Served(entry *Entry, coding string, bytes int)
+
+	// Evicted is called when entry was evicted or expired because of the limits of the cache.
+	Evicted(entry *Entry)
+}
+
+// metrics are the counters of a cache.
+// It must be the first field of Cache, so that its 64-bit counters are aligned for atomic access on 32-bit platforms.
+type metrics struct {
+	hits        int64
+	misses      int64
+	notModified int64
+	evictions   int64
+
+	// The bytes served by content coding, in the order of codings.
+	bytes [4]int64
+}
+
+// The content codings for which bytes served are counted.
+var codings = []string{Brotli, Zstd, Gzip, Identity}
+
+// SetRecorder sets a recorder which receives the events of this cache in addition to its own metrics, or nil for none.
+func (c *Cache) SetRecorder(recorder Recorder) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.recorder = recorder
+}
+
+func SetRecorder(recorder Recorder) {
+	GlobalCache.SetRecorder(recorder)
+}
+
+// getRecorder returns the recorder of this cache, or nil.
+func (c *Cache) getRecorder() Recorder {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.recorder
+}
+
+// recordHit counts that entry is served.
+func (c *Cache) recordHit(entry *Entry) {
+	if c == nil {
+		return
+	}
+	atomic.AddInt64(&c.metrics.hits, 1)
+	if recorder := c.getRecorder(); recorder != nil {
+		recorder.Hit(entry)
+	}
+}
+
+// recordMiss counts that there is no entry for uri.
+func (c *Cache) recordMiss(uri string) {
+	atomic.AddInt64(&c.metrics.misses, 1)
+	if recorder := c.getRecorder(); recorder != nil {
+		recorder.Miss(uri)
+	}
+}
+
+// recordNotModified counts that entry is served as 304 Not Modified.
+func (c *Cache) recordNotModified(entry *Entry) {
+	if c == nil {
+		return
+	}
+	atomic.AddInt64(&c.metrics.notModified, 1)
+	if recorder := c.getRecorder(); recorder != nil {
+		recorder.NotModified(entry)
+	}
+}
+
+// recordServed counts the bytes of entry sent with the given content coding.
+func (c *Cache) recordServed(entry *Entry, coding string, bytes int) {
+	if c == nil {
+		return
+	}
+	for i, known := range codings {
+		if known == coding {
+			atomic.AddInt64(&c.metrics.bytes[i], int64(bytes))
+		}
+	}
+	if recorder := c.getRecorder(); recorder != nil {
+		recorder.Served(entry, coding, bytes)
+	}
+}
+
+// recordEvicted counts the evicted entries.
+func (c *Cache) recordEvicted(evicted []*Entry) {
+	atomic.AddInt64(&c.metrics.evictions, int64(len(evicted)))
+	if recorder := c.getRecorder(); recorder != nil {
+		for _, entry := range evicted {
+			recorder.Evicted(entry)
+		}
+	}
+}
+
+// MetricsHandler returns a handler that serves the metrics of this cache in the Prometheus text exposition format.
+// The metrics are:
+//
+//	http_cache_hits_total                  counter  entries served
+//	http_cache_misses_total                counter  requests to ServeCacheEntry without entry
+//	http_cache_not_modified_total          counter  entries served as 304 Not Modified
+//	http_cache_served_bytes_total          counter  body bytes served, by encoding
+//	http_cache_evictions_total             counter  entries evicted or expired because of the limits
+//	http_cache_entries                     gauge    entries in the cache
+//	http_cache_memory_bytes                gauge    bytes used by the bodies of all entries, see Size
+//	http_cache_compression_ratio           gauge    uncompressed bytes per compressed byte of all entries, by encoding
+func (c *Cache) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(header.ContentType, "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set(header.CacheControl, "no-store")
+		_, _ = w.Write([]byte(c.exposition()))
+	})
+}
+
+func MetricsHandler() http.Handler {
+	return GlobalCache.MetricsHandler()
+}
+
+// exposition returns the metrics of this cache in the Prometheus text exposition format.
+func (c *Cache) exposition() string {
+	var b strings.Builder
+	metric := func(name string, kind string, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	metric("http_cache_hits_total", "counter", "Entries served.")
+	fmt.Fprintf(&b, "http_cache_hits_total %d\n", atomic.LoadInt64(&c.metrics.hits))
+	metric("http_cache_misses_total", "counter", "Requests without entry.")
+	fmt.Fprintf(&b, "http_cache_misses_total %d\n", atomic.LoadInt64(&c.metrics.misses))
+	metric("http_cache_not_modified_total", "counter", "Entries served as 304 Not Modified.")
+	fmt.Fprintf(&b, "http_cache_not_modified_total %d\n", atomic.LoadInt64(&c.metrics.notModified))
+	metric("http_cache_served_bytes_total", "counter", "Body bytes served by encoding.")
+	for i, coding := range codings {
+		fmt.Fprintf(&b, "http_cache_served_bytes_total{encoding=%q} %d\n", coding, atomic.LoadInt64(&c.metrics.bytes[i]))
+	}
+	metric("http_cache_evictions_total", "counter", "Entries evicted or expired because of the limits.")
+	fmt.Fprintf(&b, "http_cache_evictions_total %d\n", atomic.LoadInt64(&c.metrics.evictions))
+	entries, memory := c.Size()
+	metric("http_cache_entries", "gauge", "Entries in the cache.")
+	fmt.Fprintf(&b, "http_cache_entries %d\n", entries)
+	metric("http_cache_memory_bytes", "gauge", "Bytes used by the bodies of all entries.")
+	fmt.Fprintf(&b, "http_cache_memory_bytes %d\n", memory)
+	metric("http_cache_compression_ratio", "gauge", "Uncompressed bytes per compressed byte of all entries by encoding.")
+	ratios := c.compressionRatios()
+	for _, coding := range codings {
+		if ratio, ok := ratios[coding]; ok {
+			fmt.Fprintf(&b, "http_cache_compression_ratio{encoding=%q} %g\n", coding, ratio)
+		}
+	}
+	return b.String()
+}
+
+// compressionRatios returns the uncompressed bytes per compressed byte of all entries by content coding.
+func (c *Cache) compressionRatios() map[string]float64 {
+	totals := make(map[string]int)
+	for _, entry := range c.Entries() {
+		for _, enc := range entry.encodings() {
+			totals[enc.coding] += len(enc.body)
+		}
+	}
+	ratios := make(map[string]float64)
+	for _, coding := range codings[:len(codings)-1] {
+		if totals[coding] > 0 {
+			ratios[coding] = float64(totals[Identity]) / float64(totals[coding])
+		}
+	}
+	return ratios
+}
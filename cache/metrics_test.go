@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// eventRecorder is a Recorder which records the events it receives.
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (r *eventRecorder) record(format string, args ...interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+}
+
+func (r *eventRecorder) Hit(entry *Entry)         { r.record("hit %s", entry.URI) }
+func (r *eventRecorder) Miss(uri string)          { r.record("miss %s", uri) }
+func (r *eventRecorder) NotModified(entry *Entry) { r.record("not-modified %s", entry.URI) }
+func (r *eventRecorder) Evicted(entry *Entry)     { r.record("evicted %s", entry.URI) }
+func (r *eventRecorder) Served(entry *Entry, coding string, bytes int) {
+	r.record("served %s %s %d", entry.URI, coding, bytes)
+}
+
+func TestMetrics(t *testing.T) {
+	c := New()
+	recorder := &eventRecorder{}
+	c.SetRecorder(recorder)
+	c.Add(&Entry{URI: "a", Body: []byte(strings.Repeat("hello ", 100)), ContentType: "text/plain"})
+	handler := c.CacheHandler(nil)
+	doRequest(handler, http.MethodGet, "/a")
+	brotli := doRequest(handler, http.MethodGet, "/a", header.AcceptEncoding, Brotli).Body.Len()
+	doRequest(handler, http.MethodGet, "/a", header.IfNoneMatch, "*")
+	doRequest(handler, http.MethodGet, "/a", header.Range, "bytes=0-9")
+	doRequest(handler, http.MethodGet, "/missing")
+	c.SetLimits(Limits{MaxEntries: 1})
+	c.Add(&Entry{URI: "b", Body: []byte("x"), ContentType: "text/plain"})
+
+	want := []string{
+		"hit a", "served a identity 600",
+		"hit a", fmt.Sprintf("served a br %d", brotli),
+		"hit a", "not-modified a",
+		"hit a", "served a identity 10",
+		"miss missing",
+		"evicted a",
+	}
+	if fmt.Sprint(recorder.events) != fmt.Sprint(want) {
+		t.Errorf("got events\n%v\nwant\n%v", recorder.events, want)
+	}
+
+	w := doRequest(c.MetricsHandler(), http.MethodGet, "/metrics")
+	for _, line := range []string{
+		"# TYPE http_cache_hits_total counter\nhttp_cache_hits_total 4\n",
+		"http_cache_misses_total 1\n",
+		"http_cache_not_modified_total 1\n",
+		fmt.Sprintf("http_cache_served_bytes_total{encoding=\"br\"} %d\n", brotli),
+		"http_cache_served_bytes_total{encoding=\"identity\"} 610\n",
+		"http_cache_evictions_total 1\n",
+		"# TYPE http_cache_entries gauge\nhttp_cache_entries 1\n",
+	} {
+		if !strings.Contains(w.Body.String(), line) {
+			t.Errorf("metrics lack %q:\n%s", line, w.Body.String())
+		}
+	}
+	if got := w.Header().Get(header.ContentType); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("got Content-Type %q", got)
+	}
+}
+
+func TestErrorEntryIsNoHit(t *testing.T) {
+	c := New()
+	c.SetErrorEntry(http.StatusNotFound, &Entry{Body: []byte("gone"), ContentType: "text/plain"})
+	w := doRequest(c.Handler(HandlerOptions{}), http.MethodGet, "/missing")
+	if w.Code != http.StatusNotFound || w.Body.String() != "gone" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	if hits, misses, bytes := atomic.LoadInt64(&c.metrics.hits), atomic.LoadInt64(&c.metrics.misses), atomic.LoadInt64(&c.metrics.bytes[3]); hits != 0 || misses != 1 || bytes != 0 {
+		t.Errorf("got %d hits, %d misses, %d bytes", hits, misses, bytes)
+	}
+}
+
+func TestMemoizeFillIsNoHit(t *testing.T) {
+	c := New()
+	memoized := c.Memoize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(header.CacheControl, "max-age=60")
+		_, _ = w.Write([]byte("x"))
+	}), MemoizeOptions{})
+	doRequest(memoized, http.MethodGet, "/m")
+	if hits := atomic.LoadInt64(&c.metrics.hits); hits != 0 {
+		t.Errorf("got %d hits after the fill", hits)
+	}
+	doRequest(memoized, http.MethodGet, "/m")
+	if hits := atomic.LoadInt64(&c.metrics.hits); hits != 1 {
+		t.Errorf("got %d hits after serving the memoized response", hits)
+	}
+}
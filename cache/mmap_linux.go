@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package cache
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the first size bytes of file read-only into memory.
+// It returns nil if the file is empty or too large to be mapped.
+func mmapFile(file *os.File, size int64) ([]byte, error) {
+	if size == 0 || int64(int(size)) != size {
+		return nil, nil
+	}
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// munmap unmaps memory mapped by mmapFile.
+func munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
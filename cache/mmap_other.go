@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package cache
+
+import (
+	"os"
+)
+
+// mmapFile returns nil, as memory-mapping is only supported on Linux, so the file is read when served.
+func mmapFile(file *os.File, size int64) ([]byte, error) {
+	return nil, nil
+}
+
+// munmap does nothing, as mmapFile maps nothing.
+func munmap(data []byte) error {
+	return nil
+}
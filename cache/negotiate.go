@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// negotiate selects the representation of this entry or one of its Variants which best matches
+// the Accept, Accept-Language and other request header fields of r, as per RFC 9110 section 12.1.
+// It also returns the names of the request header fields which influenced the selection, for the Vary header field.
+// If none of the representations is acceptable, this entry itself is selected,
+// as serving a default representation is more useful to most clients than 406 Not Acceptable.
+func (e *Entry) negotiate(r *http.Request) (*Entry, []string) {
+	if len(e.Variants) == 0 {
+		return e, nil
+	}
+	candidates := append([]*Entry{e}, e.Variants...)
+	accept := parseQualityList(r.Header.Values(header.Accept))
+	acceptLanguage := parseQualityList(r.Header.Values(header.AcceptLanguage))
+	best, bestQ, bestMatches := e, 0.0, 0
+	for _, candidate := range candidates {
+		matches, ok := candidate.matchesRequest(r)
+		if !ok {
+			continue
+		}
+		q := mediaTypeQuality(accept, candidate.ContentType) * languageQuality(acceptLanguage, candidate.Language)
+		if q > bestQ || q > 0 && q == bestQ && matches > bestMatches {
+			best, bestQ, bestMatches = candidate, q, matches
+		}
+	}
+	return best, varyDimensions(candidates)
+}
+
+// matchesRequest returns how many of the Match header fields of this entry are satisfied by r,
+// and false if any of them is not satisfied.
+func (e *Entry) matchesRequest(r *http.Request) (int, bool) {
+	for name, values := range e.Match {
+		actual := strings.TrimSpace(r.Header.Get(name))
+		matched := false
+		for _, value := range values {
+			if strings.EqualFold(actual, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return 0, false
+		}
+	}
+	return len(e.Match), true
+}
+
+// varyDimensions returns the names of the request header fields along which the candidates differ.
+func varyDimensions(candidates []*Entry) []string {
+	var vary []string
+	contentTypes := make(map[string]bool)
+	languages := make(map[string]bool)
+	matched := make(map[string]bool)
+	for _, candidate := range candidates {
+		contentTypes[mediaType(candidate.ContentType)] = true
+		languages[strings.ToLower(candidate.Language)] = true
+		for name := range candidate.Match {
+			name = http.CanonicalHeaderKey(name)
+			if !matched[name] {
+				matched[name] = true
+				vary = append(vary, name)
+			}
+		}
+	}
+	sort.Strings(vary)
+	if len(languages) > 1 {
+		vary = append([]string{header.AcceptLanguage}, vary...)
+	}
+	if len(contentTypes) > 1 {
+		vary = append([]string{header.Accept}, vary...)
+	}
+	return vary
+}
+
+// qualityList is a parsed list of values with weights, like in Accept or Accept-Language.
+// A nil qualityList means that the header field is absent and everything is acceptable.
+type qualityList map[string]float64
+
+// parseQualityList parses the values of a header field with weighted elements.
+// It returns nil if there are no values.
+func parseQualityList(values []string) qualityList {
+	if len(values) == 0 {
+		return nil
+	}
+	list := make(qualityList)
+	for _, element := range strings.Split(strings.Join(values, ","), ",") {
+		if value, q, ok := parseQualityElement(element); ok && value != "" {
+			list[value] = q
+		}
+	}
+	return list
+}
+
+// mediaType returns the lower-case media type of a content type without parameters.
+func mediaType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// mediaTypeQuality returns the quality of contentType according to the Accept header field parsed into accept.
+// The most specific matching media range determines the quality.
+func mediaTypeQuality(accept qualityList, contentType string) float64 {
+	if accept == nil {
+		return 1
+	}
+	t := mediaType(contentType)
+	if q, ok := accept[t]; ok {
+		return q
+	}
+	if i := strings.IndexByte(t, '/'); i >= 0 {
+		if q, ok := accept[t[:i]+"/*"]; ok {
+			return q
+		}
+	}
+	return accept["*/*"]
+}
+
+// languageQuality returns the quality of the language tag according to the Accept-Language header field parsed into accept.
+// The longest language range matching the tag as per the basic filtering of RFC 4647 section 3.3.1 determines the quality.
+// If there is none, a range of which the tag is a prefix is used, like for the lookup of RFC 4647 section 3.4,
+// so that "de" is acceptable for "de-CH".
+// A representation without language is acceptable, but less preferred than any language listed by the client.
+func languageQuality(accept qualityList, language string) float64 {
+	if accept == nil {
+		return 1
+	}
+	if language == "" {
+		return 0.001
+	}
+	tag := strings.ToLower(language)
+	filtered, filteredLength := 0.0, -1
+	lookup, hasLookup := 0.0, false
+	for languageRange, q := range accept {
+		switch {
+		case tag == languageRange || strings.HasPrefix(tag, languageRange+"-"):
+			if len(languageRange) > filteredLength {
+				filtered, filteredLength = q, len(languageRange)
+			}
+		case strings.HasPrefix(languageRange, tag+"-"):
+			if !hasLookup || q > lookup {
+				lookup, hasLookup = q, true
+			}
+		}
+	}
+	switch {
+	case filteredLength >= 0:
+		return filtered
+	case hasLookup:
+		return lookup
+	default:
+		return accept["*"]
+	}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"net/http"
+	"testing"
+)
+
+func TestNegotiateVariants(t *testing.T) {
+	entry := &Entry{URI: "index.html", Body: []byte("en"), ContentType: mimetype.TextHtml, Language: "en", Variants: []*Entry{
+		{URI: "index.de.html", Body: []byte("de"), ContentType: mimetype.TextHtml, Language: "de"},
+		{URI: "index.json", Body: []byte("{}"), ContentType: mimetype.ApplicationJson, Language: "en"},
+		{URI: "index.dark.html", Body: []byte("dark"), ContentType: mimetype.TextHtml, Language: "en", Match: http.Header{"Sec-CH-Prefers-Color-Scheme": {"dark"}}},
+	}}
+	entry.prepare()
+	tests := []struct {
+		name            string
+		fields          []string
+		body            string
+		contentLocation string
+	}{
+		{"no preferences", nil, "en", ""},
+		{"language", []string{header.AcceptLanguage, "de-CH, en;q=0.5"}, "de", "/index.de.html"},
+		{"media type", []string{header.Accept, mimetype.ApplicationJson}, "{}", "/index.json"},
+		{"unknown language", []string{header.Accept, "text/html,*/*;q=0.1", header.AcceptLanguage, "fr"}, "en", ""},
+		{"match", []string{"Sec-CH-Prefers-Color-Scheme", "dark"}, "dark", "/index.dark.html"},
+		{"nothing acceptable", []string{header.Accept, "image/png"}, "en", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := doRequest(http.HandlerFunc(entry.Serve), http.MethodGet, "/", test.fields...)
+			if w.Code != http.StatusOK || w.Body.String() != test.body {
+				t.Errorf("got %d %q, want %q", w.Code, w.Body.String(), test.body)
+			}
+			if got := w.Header().Get(header.ContentLocation); got != test.contentLocation {
+				t.Errorf("got Content-Location %q, want %q", got, test.contentLocation)
+			}
+			if got := w.Header().Get(header.Vary); got != "Accept, Accept-Language, Sec-Ch-Prefers-Color-Scheme, Accept-Encoding" {
+				t.Errorf("got Vary %q", got)
+			}
+		})
+	}
+}
+
+func TestLanguageQuality(t *testing.T) {
+	accept := parseQualityList([]string{"de-CH, de;q=0.8, en-US;q=0.5, *;q=0.1"})
+	tests := []struct {
+		language string
+		q        float64
+	}{
+		{"de-CH", 1},
+		{"de", 0.8},
+		{"de-AT", 0.8},
+		{"en", 0.5},
+		{"fr", 0.1},
+		{"", 0.001},
+	}
+	for _, test := range tests {
+		if q := languageQuality(accept, test.language); q != test.q {
+			t.Errorf("languageQuality(%q) = %v, want %v", test.language, q, test.q)
+		}
+	}
+}
+
+func TestMediaTypeQuality(t *testing.T) {
+	accept := parseQualityList([]string{"text/html, text/*;q=0.5, */*;q=0.1"})
+	tests := []struct {
+		contentType string
+		q           float64
+	}{
+		{"text/html; charset=utf-8", 1},
+		{"TEXT/HTML", 1},
+		{"text/plain", 0.5},
+		{"image/png", 0.1},
+	}
+	for _, test := range tests {
+		if q := mediaTypeQuality(accept, test.contentType); q != test.q {
+			t.Errorf("mediaTypeQuality(%q) = %v, want %v", test.contentType, q, test.q)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package cache
+
+import (
+	"context"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"net/http/httputil"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Proxy is a caching reverse proxy.
+// It serves GET and HEAD requests from its cache, and forwards cache misses to its upstream handler.
+// Responses are stored according to their Cache-Control, Expires, Vary, ETag and Last-Modified header fields,
+// following the rules of RFC 9111 for shared caches.
+// Stale entries are revalidated with conditional requests.
+// The stale-while-revalidate and stale-if-error extensions of RFC 5861 are supported.
+// Only 200 responses without Content-Encoding and without Set-Cookie are stored,
+// and only if their body does not exceed the MaxBytes of the limits of the cache.
+// Other responses are streamed to the client as they are received.
+// Their compressed representations are computed by the cache, so the upstream is asked for uncompressed responses.
+type Proxy struct {
+	cache    *Cache
+	upstream http.Handler
+
+	mutex sync.Mutex
+
+	// The names of the request header fields listed in the Vary of the latest response per request URI.
+	varies map[string][]string
+
+	// The keys of the entries currently being revalidated in the background.
+	revalidating map[string]bool
+}
+
+// freshness describes for how long an entry stored by a Proxy may be served.
+type freshness struct {
+
+	// The time at which the age of the response was zero.
+	born time.Time
+
+	// The freshness lifetime, see RFC 9111 section 4.2.1.
+	lifetime time.Duration
+
+	// For how long a stale response may be served while it is revalidated in the background.
+	staleWhileRevalidate time.Duration
+
+	// For how long a stale response may be served if revalidation fails.
+	staleIfError time.Duration
+
+	// The header fields of the upstream response, used for revalidation and updated by 304 responses.
+	upstream http.Header
+}
+
+// NewProxy creates a caching reverse proxy for upstream which stores responses in c.
+// The cache should be dedicated to the proxy, as the URIs of the stored entries are internal keys.
+func NewProxy(c *Cache, upstream http.Handler) *Proxy {
+	return &Proxy{cache: c, upstream: upstream, varies: make(map[string][]string), revalidating: make(map[string]bool)}
+}
+
+// NewURLProxy creates a caching reverse proxy for the server at target which stores responses in c.
+func NewURLProxy(c *Cache, target *url.URL) *Proxy {
+	return NewProxy(c, httputil.NewSingleHostReverseProxy(target))
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	base := r.URL.RequestURI()
+	if !isSafe(r.Method) {
+		// RFC 9111 section 4.4: unsafe methods invalidate the stored response.
+		p.cache.Remove(p.key(base, r))
+		p.upstream.ServeHTTP(w, r)
+		return
+	}
+	requestDirectives := parseCacheControl(r.Header)
+	if _, noStore := requestDirectives["no-store"]; noStore || r.Header.Get(header.Authorization) != "" {
+		p.upstream.ServeHTTP(w, r)
+		return
+	}
+	entry, ok := p.cache.Get(p.key(base, r))
+	if !ok || entry.freshness == nil {
+		p.fetch(w, r, base, nil)
+		return
+	}
+	_, noCache := requestDirectives["no-cache"]
+	noCache = noCache || requestDirectives["max-age"] == "0"
+	now := time.Now()
+	f := entry.freshness
+	switch {
+	case noCache:
+		p.fetch(w, r, base, entry)
+	case f.isFresh(now):
+		p.serveAged(w, r, entry, now, true)
+	case f.staleness(now) < f.staleWhileRevalidate:
+		p.revalidateInBackground(r, base, entry)
+		p.serveAged(w, r, entry, now, true)
+	default:
+		p.fetch(w, r, base, entry)
+	}
+}
+
+// fetch forwards r to the upstream, stores the response if possible, and serves it.
+// If stale is not nil, the request is made conditional on the validators of stale.
+func (p *Proxy) fetch(w http.ResponseWriter, r *http.Request, base string, stale *Entry) {
+	response := p.forward(w, r, stale)
+	now := time.Now()
+	switch {
+	case response.relayed:
+	case stale != nil && response.status == http.StatusNotModified:
+		p.serveAged(w, r, p.refresh(stale, response.header, now), now, false)
+	case stale != nil && response.status >= http.StatusInternalServerError && stale.freshness.staleness(now) < stale.freshness.staleIfError:
+		p.serveAged(w, r, stale, now, true)
+	default:
+		if entry := p.store(base, r, response, now); entry != nil {
+			p.serveAged(w, r, entry, now, false)
+		} else {
+			response.writeTo(w)
+		}
+	}
+}
+
+// forward sends r as GET request to the upstream and returns the captured response.
+// Responses which are neither stored nor needed to serve stale are relayed to w instead, see isKept.
+// The conditional header fields of the client are removed, as the cache needs the full response.
+// If stale is not nil, the request is made conditional on the validators of stale instead.
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request, stale *Entry) *capture {
+	out := r.Clone(r.Context())
+	out.Method = http.MethodGet
+	out.Header.Del(header.AcceptEncoding)
+	for _, name := range conditionalHeaders {
+		out.Header.Del(name)
+	}
+	if stale != nil {
+		if etag := stale.freshness.upstream.Get(header.ETag); etag != "" {
+			out.Header.Set(header.IfNoneMatch, etag)
+		}
+		if lastModified := stale.freshness.upstream.Get(header.LastModified); lastModified != "" {
+			out.Header.Set(header.IfModifiedSince, lastModified)
+		}
+	}
+	keep := func(status int, h http.Header) bool {
+		return isKept(status, h, stale, time.Now())
+	}
+	response := newRelay(w, keep, p.cache.maxBytes())
+	p.upstream.ServeHTTP(response, out)
+	response.WriteHeader(http.StatusOK)
+	return response
+}
+
+// isKept returns true if a response with the given status and header fields received at now is needed by the Proxy,
+// because it may be stored, or because it revalidates stale, or makes it be served as per stale-if-error.
+func isKept(status int, h http.Header, stale *Entry, now time.Time) bool {
+	if stale != nil && (status == http.StatusNotModified || status >= http.StatusInternalServerError && stale.freshness.staleness(now) < stale.freshness.staleIfError) {
+		return true
+	}
+	if status != http.StatusOK {
+		return false
+	}
+	if _, ok := newFreshness(h, now); !ok {
+		return false
+	}
+	_, ok := varyNames(h)
+	return ok
+}
+
+// revalidateInBackground revalidates stale in a new goroutine, unless it is already being revalidated.
+func (p *Proxy) revalidateInBackground(r *http.Request, base string, stale *Entry) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.revalidating[stale.URI] {
+		return
+	}
+	p.revalidating[stale.URI] = true
+	out := r.Clone(context.Background())
+	go func() {
+		defer func() {
+			p.mutex.Lock()
+			delete(p.revalidating, stale.URI)
+			p.mutex.Unlock()
+		}()
+		response := p.forward(discard(make(http.Header)), out, stale)
+		now := time.Now()
+		if response.status == http.StatusNotModified {
+			p.refresh(stale, response.header, now)
+		} else {
+			p.store(base, out, response, now)
+		}
+	}()
+}
+
+// store stores the response to r in the cache, if it is cacheable, and returns its entry.
+func (p *Proxy) store(base string, r *http.Request, response *capture, now time.Time) *Entry {
+	if response.relayed || response.status != http.StatusOK {
+		return nil
+	}
+	f, ok := newFreshness(response.header, now)
+	if !ok {
+		return nil
+	}
+	vary, ok := varyNames(response.header)
+	if !ok {
+		return nil
+	}
+	p.mutex.Lock()
+	p.varies[base] = vary
+	p.mutex.Unlock()
+	entry := response.entry(varyKey(base, vary, r))
+	entry.freshness = f
+	p.cache.Add(entry)
+	return entry
+}
+
+// refresh updates stale with the header fields of a 304 response, as per RFC 9111 section 4.3.4, and returns the updated entry.
+func (p *Proxy) refresh(stale *Entry, notModified http.Header, now time.Time) *Entry {
+	merged := stale.freshness.upstream.Clone()
+	for key, values := range notModified {
+		switch key {
+		case header.ContentLength, header.ContentType, header.ContentEncoding:
+		default:
+			merged[key] = values
+		}
+	}
+	entry := *stale
+	entry.Header = endToEndHeader(merged)
+	if f, ok := newFreshness(merged, now); ok {
+		entry.freshness = f
+	} else {
+		entry.freshness = &freshness{born: now, upstream: merged}
+	}
+	p.cache.replaceIfSame(stale, &entry)
+	return &entry
+}
+
+// key returns the cache key for r, taking the Vary of the latest response for base into account.
+func (p *Proxy) key(base string, r *http.Request) string {
+	p.mutex.Lock()
+	vary := p.varies[base]
+	p.mutex.Unlock()
+	return varyKey(base, vary, r)
+}
+
+// varyKey returns the cache key for r, made of base and the values of the request header fields listed in vary.
+func varyKey(base string, vary []string, r *http.Request) string {
+	key := base
+	for _, name := range vary {
+		key += "\x00" + name + ":" + strings.Join(r.Header.Values(name), ",")
+	}
+	return key
+}
+
+// varyNames returns the sorted names of the request header fields listed in the Vary of a response.
+// Accept-Encoding is omitted, because the cache negotiates the content coding itself.
+// It returns false for "Vary: *", which makes a response uncacheable.
+func varyNames(h http.Header) ([]string, bool) {
+	var names []string
+	for _, value := range h.Values(header.Vary) {
+		for _, name := range strings.Split(value, ",") {
+			name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+			switch name {
+			case "":
+			case "*":
+				return nil, false
+			case header.AcceptEncoding:
+			default:
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// newFreshness determines the freshness of a 200 response received at now, as per RFC 9111 sections 3 and 4.2.
+// It returns false if the response must not be stored by a shared cache, or would be useless to store.
+func newFreshness(h http.Header, now time.Time) (*freshness, bool) {
+	directives := parseCacheControl(h)
+	_, noStore := directives["no-store"]
+	_, private := directives["private"]
+	if noStore || private || h.Get(header.SetCookie) != "" {
+		return nil, false
+	}
+	if encoding := h.Get(header.ContentEncoding); encoding != "" && encoding != Identity {
+		return nil, false
+	}
+	date, ok := parseHTTPDate(h.Get(header.Date))
+	if !ok {
+		date = now
+	}
+	f := &freshness{born: now, upstream: h}
+	if age, err := strconv.Atoi(h.Get(header.Age)); err == nil && age > 0 {
+		f.born = now.Add(-time.Duration(age) * time.Second)
+	}
+	_, noCache := directives["no-cache"]
+	if sMaxAge, ok := directiveSeconds(directives, "s-maxage"); ok {
+		f.lifetime = sMaxAge
+	} else if maxAge, ok := directiveSeconds(directives, "max-age"); ok {
+		f.lifetime = maxAge
+	} else if expires := h.Get(header.Expires); expires != "" {
+		if t, ok := parseHTTPDate(expires); ok {
+			f.lifetime = t.Sub(date)
+		}
+	} else if lastModified, ok := parseHTTPDate(h.Get(header.LastModified)); ok {
+		// Heuristic freshness, RFC 9111 section 4.2.2.
+		f.lifetime = date.Sub(lastModified) / 10
+	}
+	if noCache {
+		f.lifetime = 0
+	}
+	_, mustRevalidate := directives["must-revalidate"]
+	_, proxyRevalidate := directives["proxy-revalidate"]
+	if !noCache && !mustRevalidate && !proxyRevalidate {
+		f.staleWhileRevalidate, _ = directiveSeconds(directives, "stale-while-revalidate")
+		f.staleIfError, _ = directiveSeconds(directives, "stale-if-error")
+	}
+	if f.lifetime <= 0 && f.staleWhileRevalidate == 0 && f.staleIfError == 0 && h.Get(header.ETag) == "" && h.Get(header.LastModified) == "" {
+		// Useless to store, it could never be served without fetching it again.
+		return nil, false
+	}
+	return f, true
+}
+
+// age returns the current age of the response.
+func (f *freshness) age(now time.Time) time.Duration {
+	return now.Sub(f.born)
+}
+
+// isFresh returns true if the response is fresh.
+func (f *freshness) isFresh(now time.Time) bool {
+	return f.age(now) < f.lifetime
+}
+
+// staleness returns for how long the response has been stale.
+func (f *freshness) staleness(now time.Time) time.Duration {
+	return f.age(now) - f.lifetime
+}
+
+// serveAged serves an entry stored by this Proxy with its Age header field.
+// If hit is false, the entry was just fetched from the upstream, and is not counted as a hit.
+func (p *Proxy) serveAged(w http.ResponseWriter, r *http.Request, entry *Entry, now time.Time, hit bool) {
+	w.Header().Set(header.Age, strconv.Itoa(int(entry.freshness.age(now).Seconds())))
+	entry.serve(w, r, p.cache, "", hit)
+}
+
+// hopByHop lists the header fields of an upstream response which are not forwarded,
+// either because they are hop-by-hop fields, or because Entry.Serve generates them.
+var hopByHop = []string{
+	header.AcceptRanges,
+	header.Age,
+	header.Connection,
+	header.ContentEncoding,
+	header.ContentLength,
+	header.ContentType,
+	header.Date,
+	header.ETag,
+	header.KeepAlive,
+	header.LastModified,
+	header.ProxyAuthenticate,
+	header.ProxyAuthorization,
+	header.SetCookie,
+	header.TE,
+	header.Trailer,
+	header.TransferEncoding,
+	header.Upgrade,
+}
+
+// endToEndHeader returns a copy of the header fields of an upstream response which are forwarded to clients.
+func endToEndHeader(h http.Header) http.Header {
+	result := h.Clone()
+	for _, value := range h.Values(header.Connection) {
+		for _, name := range strings.Split(value, ",") {
+			result.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopByHop {
+		result.Del(name)
+	}
+	return result
+}
+
+// parseCacheControl parses the Cache-Control header fields of h into a map of lower-case directive names to their unquoted values.
+func parseCacheControl(h http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range h.Values(header.CacheControl) {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, argument := directive, ""
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				name, argument = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+			directives[strings.ToLower(strings.TrimSpace(name))] = argument
+		}
+	}
+	return directives
+}
+
+// directiveSeconds returns the value of a delta-seconds cache directive as duration.
+func directiveSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testUpstream is a server for testing a Proxy, which counts the requests per path.
+type testUpstream struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	calls   map[string]int
+	failing bool
+}
+
+// newUpstream starts an upstream whose responses have the Cache-Control given by path.
+// The body of each response contains the path, the number of the request, and the Accept-Language of the request.
+func newUpstream(t *testing.T, cacheControl map[string]string) *testUpstream {
+	u := &testUpstream{calls: make(map[string]int)}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mutex.Lock()
+		u.calls[r.URL.Path]++
+		n, failing := u.calls[r.URL.Path], u.failing
+		u.mutex.Unlock()
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set(header.CacheControl, cacheControl[r.URL.Path])
+		if r.URL.Path == "/etag" {
+			if r.Header.Get(header.IfNoneMatch) == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set(header.ETag, `"v1"`)
+		}
+		if r.URL.Path == "/vary" {
+			w.Header().Set(header.Vary, header.AcceptLanguage)
+		}
+		_, _ = fmt.Fprintf(w, "%s %d %s", r.URL.Path, n, r.Header.Get(header.AcceptLanguage))
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// callsTo returns the number of requests for path which reached the upstream.
+func (u *testUpstream) callsTo(path string) int {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.calls[path]
+}
+
+// fail makes the upstream answer all further requests with 503 Service Unavailable.
+func (u *testUpstream) fail() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.failing = true
+}
+
+// newTestProxy creates a proxy for u with a new cache.
+func newTestProxy(t *testing.T, u *testUpstream) (*Proxy, *Cache) {
+	target, err := url.Parse(u.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New()
+	return NewURLProxy(c, target), c
+}
+
+func TestProxyStoresFreshResponses(t *testing.T) {
+	u := newUpstream(t, map[string]string{"/fresh": "max-age=60"})
+	p, c := newTestProxy(t, u)
+	for i := 0; i < 3; i++ {
+		w := doRequest(p, http.MethodGet, "/fresh", header.AcceptEncoding, "identity")
+		if w.Code != http.StatusOK || w.Body.String() != "/fresh 1 " {
+			t.Fatalf("got %d %q", w.Code, w.Body.String())
+		}
+		if w.Header().Get(header.Age) == "" {
+			t.Error("got no Age")
+		}
+		if got := w.Header().Get(header.ContentType); got != "text/plain; charset=utf-8" {
+			t.Errorf("got Content-Type %q", got)
+		}
+	}
+	if calls := u.callsTo("/fresh"); calls != 1 {
+		t.Errorf("got %d upstream calls, want 1", calls)
+	}
+	if hits := atomic.LoadInt64(&c.metrics.hits); hits != 2 {
+		t.Errorf("got %d hits, want 2, as the fill is no hit", hits)
+	}
+	if w := doRequest(p, http.MethodGet, "/fresh", header.AcceptEncoding, Gzip); w.Header().Get(header.ContentEncoding) != Gzip {
+		t.Error("the stored response is not served compressed")
+	}
+}
+
+func TestProxyForwardsUncacheableRequests(t *testing.T) {
+	u := newUpstream(t, map[string]string{"/nostore": "no-store", "/private": "max-age=60"})
+	p, _ := newTestProxy(t, u)
+	doRequest(p, http.MethodGet, "/nostore")
+	doRequest(p, http.MethodGet, "/nostore")
+	if calls := u.callsTo("/nostore"); calls != 2 {
+		t.Errorf("got %d upstream calls for no-store, want 2", calls)
+	}
+	doRequest(p, http.MethodGet, "/private", header.Authorization, "Basic Zm9vOmJhcg==")
+	doRequest(p, http.MethodGet, "/private", header.Authorization, "Basic Zm9vOmJhcg==")
+	if calls := u.callsTo("/private"); calls != 2 {
+		t.Errorf("got %d upstream calls with Authorization, want 2", calls)
+	}
+}
+
+func TestProxyInvalidatesOnUnsafeMethods(t *testing.T) {
+	u := newUpstream(t, map[string]string{"/fresh": "max-age=60"})
+	p, _ := newTestProxy(t, u)
+	doRequest(p, http.MethodGet, "/fresh")
+	doRequest(p, http.MethodPost, "/fresh")
+	if w := doRequest(p, http.MethodGet, "/fresh"); w.Body.String() != "/fresh 3 " {
+		t.Errorf("got %q after POST, want a new response", w.Body.String())
+	}
+}
+
+func TestProxyVary(t *testing.T) {
+	u := newUpstream(t, map[string]string{"/vary": "max-age=60"})
+	p, _ := newTestProxy(t, u)
+	for _, test := range []struct{ language, body string }{{"de", "/vary 1 de"}, {"en", "/vary 2 en"}, {"de", "/vary 1 de"}} {
+		if w := doRequest(p, http.MethodGet, "/vary", header.AcceptLanguage, test.language); w.Body.String() != test.body {
+			t.Errorf("got %q for %s, want %q", w.Body.String(), test.language, test.body)
+		}
+	}
+}
+
+func TestProxyRevalidates(t *testing.T) {
+	u := newUpstream(t, map[string]string{"/etag": "max-age=0"})
+	p, _ := newTestProxy(t, u)
+	doRequest(p, http.MethodGet, "/etag")
+	w := doRequest(p, http.MethodGet, "/etag")
+	if w.Code != http.StatusOK || w.Body.String() != "/etag 1 " {
+		t.Errorf("got %d %q, want the stored response", w.Code, w.Body.String())
+	}
+	if calls := u.callsTo("/etag"); calls != 2 {
+		t.Errorf("got %d upstream calls, want 2", calls)
+	}
+}
+
+func TestProxyStaleIfError(t *testing.T) {
+	u := newUpstream(t, map[string]string{"/stale": "max-age=0, stale-if-error=60", "/fresh": "max-age=0"})
+	p, _ := newTestProxy(t, u)
+	doRequest(p, http.MethodGet, "/stale")
+	u.fail()
+	if w := doRequest(p, http.MethodGet, "/stale"); w.Code != http.StatusOK || w.Body.String() != "/stale 1 " {
+		t.Errorf("got %d %q, want the stale response", w.Code, w.Body.String())
+	}
+	if w := doRequest(p, http.MethodGet, "/fresh"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d without stale response, want 503", w.Code)
+	}
+}
+
+func TestProxyStaleWhileRevalidate(t *testing.T) {
+	u := newUpstream(t, map[string]string{"/swr": "max-age=0, stale-while-revalidate=60"})
+	p, _ := newTestProxy(t, u)
+	doRequest(p, http.MethodGet, "/swr")
+	if w := doRequest(p, http.MethodGet, "/swr"); w.Code != http.StatusOK || w.Body.String() != "/swr 1 " {
+		t.Fatalf("got %d %q, want the stale response", w.Code, w.Body.String())
+	}
+	waitRevalidated(t, p)
+	if calls := u.callsTo("/swr"); calls != 2 {
+		t.Errorf("got %d upstream calls, want the background revalidation", calls)
+	}
+	if w := doRequest(p, http.MethodGet, "/swr"); w.Code != http.StatusOK || w.Body.String() != "/swr 2 " {
+		t.Errorf("got %d %q, want the revalidated response", w.Code, w.Body.String())
+	}
+	waitRevalidated(t, p)
+}
+
+// waitRevalidated waits until p has finished revalidating entries in the background.
+func waitRevalidated(t *testing.T, p *Proxy) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		p.mutex.Lock()
+		revalidating := len(p.revalidating)
+		p.mutex.Unlock()
+		if revalidating == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the background revalidation did not finish")
+		}
+	}
+}
+
+func TestProxyStreamsUnstoredResponses(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		cacheControl string
+		maxBytes     int
+	}{
+		{"no-store", http.StatusOK, "no-store", 0},
+		{"status", http.StatusNotFound, "max-age=60", 0},
+		{"too large", http.StatusOK, "max-age=60", 8},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		upstream := http.HandlerFunc(func(upstreamWriter http.ResponseWriter, r *http.Request) {
+			upstreamWriter.Header().Set(header.CacheControl, test.cacheControl)
+			upstreamWriter.WriteHeader(test.status)
+			_, _ = upstreamWriter.Write([]byte("first part, "))
+			upstreamWriter.(http.Flusher).Flush()
+			if w.Code != test.status || w.Body.String() != "first part, " || !w.Flushed {
+				t.Errorf("%s: got %d %q before the upstream finished, want the streamed first part", test.name, w.Code, w.Body.String())
+			}
+			_, _ = upstreamWriter.Write([]byte("second part"))
+		})
+		c := New()
+		c.SetLimits(Limits{MaxBytes: test.maxBytes})
+		NewProxy(c, upstream).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+		if w.Code != test.status || w.Body.String() != "first part, second part" || w.Header().Get(header.CacheControl) != test.cacheControl {
+			t.Errorf("%s: got %d %q %v", test.name, w.Code, w.Body.String(), w.Header())
+		}
+		if entries, _ := c.Size(); entries != 0 {
+			t.Errorf("%s: got %d entries, want none", test.name, entries)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Bytes is the constant for the range unit "bytes", used in Accept-Ranges, Range and Content-Range.
+	Bytes = "bytes"
+)
+
+// errUnsatisfiable is returned by parseRange if none of the requested ranges overlaps the content.
+var errUnsatisfiable = errors.New("range not satisfiable")
+
+// byteRange is a single range of bytes, start inclusive, with length bytes.
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange returns the value of the Content-Range header field for this range of content with the given size.
+func (br byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("%s %d-%d/%d", Bytes, br.start, br.start+br.length-1, size)
+}
+
+// parseRange parses the value of a Range header field for content of the given size, as per RFC 9110 section 14.1.2.
+// It returns nil and no error if the header field should be ignored,
+// and errUnsatisfiable if none of the ranges is satisfiable.
+// Unsatisfiable ranges in a list with at least one satisfiable range are dropped.
+func parseRange(s string, size int64) ([]byteRange, error) {
+	prefix := Bytes + "="
+	if !strings.HasPrefix(s, prefix) {
+		return nil, nil
+	}
+	var ranges []byteRange
+	for _, spec := range strings.Split(s[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		i := strings.IndexByte(spec, '-')
+		if i < 0 {
+			return nil, nil
+		}
+		first, last := spec[:i], spec[i+1:]
+		var br byteRange
+		if first == "" {
+			// suffix-range: the last n bytes.
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, nil
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			br = byteRange{start: size - n, length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, nil
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, nil
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			if start >= size {
+				continue
+			}
+			br = byteRange{start: start, length: end - start + 1}
+		}
+		ranges = append(ranges, br)
+	}
+	if len(ranges) == 0 {
+		return nil, errUnsatisfiable
+	}
+	var total int64
+	for _, br := range ranges {
+		total += br.length
+	}
+	if total > size {
+		// Overlapping or excessive ranges, cheaper to send the full content.
+		return nil, nil
+	}
+	return ranges, nil
+}
+
+// checkIfRange returns true if the Range header field of r should be honored,
+// that is, if there is no If-Range header field, or if its validator matches the current representation.
+func checkIfRange(r *http.Request, etag string, lastModified *time.Time) bool {
+	ifRange := r.Header.Get(header.IfRange)
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return matchesETag(ifRange, etag, true)
+	}
+	if lastModified == nil {
+		return false
+	}
+	date, ok := parseHTTPDate(ifRange)
+	return ok && date.Equal(lastModified.Truncate(time.Second))
+}
+
+// serveRanges sends a 206 Partial Content response with the given ranges of body,
+// using a multipart/byteranges body if there is more than one range.
+// The multipart body of a file which is not memory-mapped is streamed without Content-Length instead of buffered.
+func serveRanges(w http.ResponseWriter, body *content, contentType string, ranges []byteRange) {
+	size := body.size
+	h := w.Header()
+	if len(ranges) == 1 {
+		br := ranges[0]
+		h.Set(header.ContentRange, br.contentRange(size))
+		h.Set(header.ContentLength, strconv.FormatInt(br.length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		body.mustWrite(w, br.start, br.length)
+		return
+	}
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	if body.file != nil {
+		mw = multipart.NewWriter(w)
+		h.Set(header.ContentType, mimetype.MultipartByteranges+"; boundary="+mw.Boundary())
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	for _, br := range ranges {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			header.ContentType:  {contentType},
+			header.ContentRange: {br.contentRange(size)},
+		})
+		if err != nil {
+			panic(err)
+		}
+		body.mustWrite(part, br.start, br.length)
+	}
+	if body.file != nil {
+		_ = mw.Close()
+		return
+	}
+	if err := mw.Close(); err != nil {
+		panic(err)
+	}
+	h.Set(header.ContentType, mimetype.MultipartByteranges+"; boundary="+mw.Boundary())
+	h.Set(header.ContentLength, strconv.Itoa(b.Len()))
+	w.WriteHeader(http.StatusPartialContent)
+	_, _ = w.Write(b.Bytes())
+}
+
+// rangesLength returns the number of bytes in ranges.
+func rangesLength(ranges []byteRange) int {
+	length := int64(0)
+	for _, br := range ranges {
+		length += br.length
+	}
+	return int(length)
+}
+
+// writeRangeNotSatisfiable sends a 416 Range Not Satisfiable response for content of the given size.
+func writeRangeNotSatisfiable(w http.ResponseWriter, size int64) {
+	h := w.Header()
+	h.Del(header.ContentType)
+	h.Set(header.ContentRange, fmt.Sprintf("%s */%d", Bytes, size))
+	w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		value  string
+		ranges []byteRange
+		err    error
+	}{
+		{"bytes=0-4", []byteRange{{0, 5}}, nil},
+		{"bytes=5-", []byteRange{{5, 5}}, nil},
+		{"bytes=-3", []byteRange{{7, 3}}, nil},
+		{"bytes=-20", []byteRange{{0, 10}}, nil},
+		{"bytes=8-20", []byteRange{{8, 2}}, nil},
+		{"bytes=0-0, -1", []byteRange{{0, 1}, {9, 1}}, nil},
+		{"bytes=0-1, 20-30", []byteRange{{0, 2}}, nil},
+		{"bytes=20-", nil, errUnsatisfiable},
+		{"bytes=-0", nil, errUnsatisfiable},
+		{"bytes=0-9, 0-9", nil, nil},
+		{"bytes=4-2", nil, nil},
+		{"bytes=x-1", nil, nil},
+		{"items=0-1", nil, nil},
+	}
+	for _, test := range tests {
+		ranges, err := parseRange(test.value, 10)
+		if !reflect.DeepEqual(ranges, test.ranges) || err != test.err {
+			t.Errorf("parseRange(%q) = %v, %v, want %v, %v", test.value, ranges, err, test.ranges, test.err)
+		}
+	}
+}
+
+func TestServeRange(t *testing.T) {
+	entry := &Entry{URI: "x", Body: []byte("0123456789"), ContentType: "text/plain"}
+	entry.prepare()
+	serve := http.HandlerFunc(entry.Serve)
+
+	w := doRequest(serve, http.MethodGet, "/x", header.Range, "bytes=2-4", header.AcceptEncoding, "gzip")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "234" {
+		t.Fatalf("got %d %q, want 206 \"234\"", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(header.ContentRange); got != "bytes 2-4/10" {
+		t.Errorf("got Content-Range %q", got)
+	}
+	if got := w.Header().Get(header.ContentEncoding); got != "" {
+		t.Errorf("got Content-Encoding %q for a range of the uncompressed body", got)
+	}
+
+	w = doRequest(serve, http.MethodGet, "/x", header.Range, "bytes=20-")
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("got %d, want 416", w.Code)
+	}
+	if got := w.Header().Get(header.ContentRange); got != "bytes */10" {
+		t.Errorf("got Content-Range %q", got)
+	}
+
+	w = doRequest(serve, http.MethodGet, "/x", header.Range, "bytes=2-4", header.IfRange, `"other"`)
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Errorf("got %d %q for a failed If-Range, want the full body", w.Code, w.Body.String())
+	}
+
+	w = doRequest(serve, http.MethodGet, "/x", header.Range, "bytes=2-4", header.IfRange, entry.ETag)
+	if w.Code != http.StatusPartialContent {
+		t.Errorf("got %d for a matching If-Range, want 206", w.Code)
+	}
+
+	w = doRequest(serve, http.MethodHead, "/x", header.Range, "bytes=2-4")
+	if w.Code != http.StatusOK {
+		t.Errorf("got %d for HEAD, want 200", w.Code)
+	}
+}
+
+func TestServeIgnoredRange(t *testing.T) {
+	entry := &Entry{URI: "x", Body: []byte(strings.Repeat("0123456789", 10)), ContentType: "text/plain"}
+	entry.prepare()
+	serve := http.HandlerFunc(entry.Serve)
+	for _, value := range []string{"items=0-1", "bytes=0-99, 0-99", "bytes=x-1"} {
+		w := doRequest(serve, http.MethodGet, "/x", header.Range, value, header.AcceptEncoding, Gzip)
+		if w.Code != http.StatusOK || w.Header().Get(header.ContentEncoding) != Gzip {
+			t.Errorf("%s: got %d, Content-Encoding %q, want the full gzip-compressed body", value, w.Code, w.Header().Get(header.ContentEncoding))
+		}
+		etag := w.Header().Get(header.ETag)
+		if w := doRequest(serve, http.MethodGet, "/x", header.Range, value, header.AcceptEncoding, Gzip, header.IfNoneMatch, etag); w.Code != http.StatusNotModified {
+			t.Errorf("%s: got %d for a matching If-None-Match, want 304", value, w.Code)
+		}
+	}
+	w := doRequest(serve, http.MethodGet, "/x", header.Range, "bytes=200-", header.IfNoneMatch, entry.ETag)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("got %d for an unsatisfiable range with a matching If-None-Match, want 304", w.Code)
+	}
+}
+
+func TestServeMultipleRanges(t *testing.T) {
+	entry := &Entry{URI: "x", Body: []byte("0123456789"), ContentType: "text/plain"}
+	entry.prepare()
+	w := doRequest(http.HandlerFunc(entry.Serve), http.MethodGet, "/x", header.Range, "bytes=0-1, -2")
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("got %d, want 206", w.Code)
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get(header.ContentType))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("got Content-Type %q", w.Header().Get(header.ContentType))
+	}
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	want := []struct{ contentRange, body string }{{"bytes 0-1/10", "01"}, {"bytes 8-9/10", "89"}}
+	for _, part := range want {
+		p, err := reader.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(p)
+		if p.Header.Get(header.ContentRange) != part.contentRange || string(body) != part.body || p.Header.Get(header.ContentType) != "text/plain" {
+			t.Errorf("got part %v %q, want %s %q", p.Header, body, part.contentRange, part.body)
+		}
+	}
+	if _, err := reader.NextPart(); err == nil {
+		t.Error("got more parts than ranges")
+	}
+}
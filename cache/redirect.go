@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// NewRedirect creates an entry which redirects requests for uri to target with the given status code,
+// like http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect or http.StatusPermanentRedirect.
+// A status code of 0 means http.StatusMovedPermanently.
+// target is sent in the Location header field as is, so it is either an absolute URL or an absolute path.
+func NewRedirect(uri string, target string, status int) *Entry {
+	if status == 0 {
+		status = http.StatusMovedPermanently
+	}
+	return &Entry{URI: uri, Redirect: target, RedirectStatus: status}
+}
+
+// AddRedirect adds an entry which redirects requests for uri to target, see NewRedirect.
+func (c *Cache) AddRedirect(uri string, target string, status int) {
+	c.Add(NewRedirect(uri, target, status))
+}
+
+func AddRedirect(uri string, target string, status int) {
+	GlobalCache.AddRedirect(uri, target, status)
+}
+
+// serveRedirect serves this redirect entry for any request method.
+// If hit is false, the response is not counted as a hit, see serve.
+func (e *Entry) serveRedirect(w http.ResponseWriter, r *http.Request, c *Cache, hit bool) {
+	if hit {
+		if e.hits != nil {
+			atomic.AddInt64(e.hits, 1)
+		}
+		c.recordHit(e)
+	}
+	status := e.RedirectStatus
+	if status == 0 {
+		status = http.StatusMovedPermanently
+	}
+	e.writeCacheControl(w, c)
+	http.Redirect(w, r, e.Redirect, status)
+}
+
+// RewriteRule maps request paths to other paths or URLs, for example to keep old URLs working after restructuring a site.
+// Rules are applied by Handler to requests without entry, or to all requests if Force is set, in the order of the rules.
+type RewriteRule struct {
+
+	// The pattern which the request path matches, with leading slash, and after removing the Prefix of the handler.
+	Pattern *regexp.Regexp
+
+	// The path or URL which replaces the request path, with $1 or ${name} replaced by the submatches of Pattern as in regexp.Expand.
+	// Paths are relative to the Prefix of the handler.
+	Replacement string
+
+	// The status code of the response.
+	// With 0 or http.StatusOK, the entry of the replacement path is served, as long as it exists.
+	// With http.StatusNotFound, the entry of the replacement path is served with 404 Not Found.
+	// With a redirect status code like http.StatusMovedPermanently, the request is redirected to the replacement.
+	Status int
+
+	// Force applies the rule even if there is an entry for the request path.
+	Force bool
+}
+
+// SetRewriteRules sets the rewrite rules of this cache, or nil for none.
+// The first matching rule wins.
+func (c *Cache) SetRewriteRules(rules []RewriteRule) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rewriteRules = rules
+}
+
+func SetRewriteRules(rules []RewriteRule) {
+	GlobalCache.SetRewriteRules(rules)
+}
+
+// LoadRedirects appends the rules of the redirects file filename to the rewrite rules of this cache, see ParseRedirects.
+func (c *Cache) LoadRedirects(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	rules, err := ParseRedirects(file)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rewriteRules = append(append([]RewriteRule(nil), c.rewriteRules...), rules...)
+	return nil
+}
+
+func LoadRedirects(filename string) error {
+	return GlobalCache.LoadRedirects(filename)
+}
+
+// getRewriteRules returns the rewrite rules of c, which may be nil.
+func (c *Cache) getRewriteRules() []RewriteRule {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.rewriteRules
+}
+
+// rewrite returns the first rule which matches requestPath, and the expanded replacement.
+// Rules without Force are only considered if force is false.
+func (c *Cache) rewrite(requestPath string, force bool) (RewriteRule, string, bool) {
+	for _, rule := range c.getRewriteRules() {
+		if force && !rule.Force {
+			continue
+		}
+		match := rule.Pattern.FindStringSubmatchIndex(requestPath)
+		if match == nil {
+			continue
+		}
+		return rule, string(rule.Pattern.ExpandString(nil, rule.Replacement, requestPath, match)), true
+	}
+	return RewriteRule{}, "", false
+}
+
+// isRedirect returns true if this rule redirects instead of serving another entry.
+func (rule *RewriteRule) isRedirect() bool {
+	return rule.Status >= 300 && rule.Status < 400
+}
+
+// The pattern of the placeholders in redirects files, like ":year".
+var placeholderPattern = regexp.MustCompile(`:[A-Za-z][A-Za-z0-9_]*`)
+
+// ParseRedirects parses redirect rules in the format of Netlify _redirects files,
+// see https://docs.netlify.com/routing/redirects/, like:
+//
+//	# from          to                 status
+//	/old            /new               301
+//	/blog/:year/*   /news/:year/:splat 302
+//	/app/*          /app/index.html    200
+//	/shop/*         https://shop.example.com/:splat 301!
+//
+// Each line has the path to match, the path or URL to redirect or rewrite to, and an optional status code, which defaults to 301.
+// A "!" after the status code forces the rule, see RewriteRule.Force.
+// The path to match may contain placeholders like ":year", which match a path segment, and a trailing "*", which matches the rest.
+// The target refers to them as ":year" and ":splat".
+// Paths without placeholders and splat also match with a trailing slash.
+// Conditions like country or role, query parameter matching and proxying to other sites are not supported, and reported as errors.
+func ParseRedirects(r io.Reader) ([]RewriteRule, error) {
+	var rules []RewriteRule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := parseRedirect(strings.Fields(text))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// parseRedirect parses the fields of a line of a redirects file.
+func parseRedirect(fields []string) (RewriteRule, error) {
+	if len(fields) < 2 || len(fields) > 3 {
+		return RewriteRule{}, fmt.Errorf("expected path, target and optional status, got %q", strings.Join(fields, " "))
+	}
+	from, to := fields[0], fields[1]
+	if !strings.HasPrefix(from, "/") {
+		return RewriteRule{}, fmt.Errorf("unsupported path %q", from)
+	}
+	rule := RewriteRule{Status: http.StatusMovedPermanently}
+	if len(fields) == 3 {
+		status := fields[2]
+		if strings.HasSuffix(status, "!") {
+			rule.Force = true
+			status = strings.TrimSuffix(status, "!")
+		}
+		var err error
+		if rule.Status, err = strconv.Atoi(status); err != nil {
+			return RewriteRule{}, fmt.Errorf("unsupported status %q", fields[2])
+		}
+	}
+	if !rule.isRedirect() && rule.Status != http.StatusOK && rule.Status != http.StatusNotFound {
+		return RewriteRule{}, fmt.Errorf("unsupported status %d", rule.Status)
+	}
+	if !rule.isRedirect() && !strings.HasPrefix(to, "/") {
+		return RewriteRule{}, fmt.Errorf("unsupported rewrite to %q", to)
+	}
+	pattern := regexp.QuoteMeta(from)
+	if strings.HasSuffix(from, "*") {
+		pattern = strings.TrimSuffix(pattern, regexp.QuoteMeta("*")) + "(?P<splat>.*)"
+	}
+	pattern = placeholderPattern.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		return "(?P<" + placeholder[1:] + ">[^/]+)"
+	})
+	if pattern == regexp.QuoteMeta(from) {
+		pattern = regexp.QuoteMeta(strings.TrimSuffix(from, "/")) + "/?"
+	}
+	var err error
+	if rule.Pattern, err = regexp.Compile("^" + pattern + "$"); err != nil {
+		return RewriteRule{}, err
+	}
+	rule.Replacement = placeholderPattern.ReplaceAllStringFunc(strings.ReplaceAll(to, "$", "$$"), func(placeholder string) string {
+		return "${" + placeholder[1:] + "}"
+	})
+	return rule, nil
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirectEntry(t *testing.T) {
+	tests := []struct {
+		name   string
+		entry  *Entry
+		status int
+	}{
+		{"NewRedirect", NewRedirect("old", "/new", http.StatusFound), http.StatusFound},
+		{"NewRedirect without status", NewRedirect("old", "/new", 0), http.StatusMovedPermanently},
+		{"Entry without RedirectStatus", &Entry{URI: "old", Redirect: "/new"}, http.StatusMovedPermanently},
+	}
+	for _, test := range tests {
+		c := New()
+		c.Add(test.entry)
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			w := doRequest(c.CacheHandler(nil), method, "/old")
+			if w.Code != test.status || w.Header().Get(header.Location) != "/new" {
+				t.Errorf("%s, %s: got %d %q", test.name, method, w.Code, w.Header().Get(header.Location))
+			}
+		}
+	}
+
+	c := New()
+	c.AddRedirect("moved", "https://example.org/", http.StatusPermanentRedirect)
+	c.Add(&Entry{URI: "page.html", Body: []byte("x"), ContentType: "text/html"})
+	if w := doRequest(c.CacheHandler(nil), http.MethodGet, "/moved"); w.Code != http.StatusPermanentRedirect || w.Header().Get(header.Location) != "https://example.org/" {
+		t.Errorf("got %d %q", w.Code, w.Header().Get(header.Location))
+	}
+	if got := len(sitemapLocs(t, c.Sitemap(httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil)))); got != 1 {
+		t.Errorf("got %d URLs in the sitemap, want the redirect left out", got)
+	}
+}
+
+func TestParseRedirects(t *testing.T) {
+	rules, err := ParseRedirects(strings.NewReader(`
+# from          to                 status
+/old            /new
+/blog/:year/*   /news/:year/:splat 302
+/shop/*         https://shop.example.com/:splat?$x 307!
+/app/*          /app/index.html    200
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		pattern     string
+		replacement string
+		status      int
+		force       bool
+	}{
+		{`^/old/?$`, "/new", http.StatusMovedPermanently, false},
+		{`^/blog/(?P<year>[^/]+)/(?P<splat>.*)$`, "/news/${year}/${splat}", http.StatusFound, false},
+		{`^/shop/(?P<splat>.*)$`, "https://shop.example.com/${splat}?$$x", http.StatusTemporaryRedirect, true},
+		{`^/app/(?P<splat>.*)$`, "/app/index.html", http.StatusOK, false},
+	}
+	if len(rules) != len(tests) {
+		t.Fatalf("got %d rules, want %d", len(rules), len(tests))
+	}
+	for i, test := range tests {
+		rule := rules[i]
+		if rule.Pattern.String() != test.pattern || rule.Replacement != test.replacement || rule.Status != test.status || rule.Force != test.force {
+			t.Errorf("got %s %s %d %v, want %s %s %d %v", rule.Pattern, rule.Replacement, rule.Status, rule.Force, test.pattern, test.replacement, test.status, test.force)
+		}
+	}
+}
+
+func TestParseRedirectsErrors(t *testing.T) {
+	for _, redirects := range []string{
+		"/a\n",
+		"/a /b 301 Country=us\n",
+		"a /b\n",
+		"/a /b xyz\n",
+		"/a /b 500\n",
+		"/a https://example.com/ 200\n",
+	} {
+		if _, err := ParseRedirects(strings.NewReader(redirects)); err == nil {
+			t.Errorf("got no error for %q", redirects)
+		}
+	}
+}
+
+func TestRewriteRules(t *testing.T) {
+	c := New()
+	for _, uri := range []string{"index.html", "app/index.html", "404.html", "kept.html", "forced.html"} {
+		c.Add(&Entry{URI: uri, Body: []byte(uri), ContentType: "text/html"})
+	}
+	rules, err := ParseRedirects(strings.NewReader(`
+/old            /new               301
+/blog/:year/*   /news/:year/:splat 302
+/app/*          /app/              200
+/kept.html      /elsewhere         301
+/forced.html    /x                 301!
+/docs/*         /404.html          404
+/missing/*      /nothing.html      200
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetRewriteRules(rules)
+	handler := c.Handler(HandlerOptions{Prefix: "/site", IndexFile: DefaultIndexFile})
+	tests := []struct {
+		method   string
+		target   string
+		status   int
+		location string
+		body     string
+	}{
+		{http.MethodGet, "/site/old/", http.StatusMovedPermanently, "/site/new", ""},
+		{http.MethodGet, "/site/old?a=1", http.StatusMovedPermanently, "/site/new?a=1", ""},
+		{http.MethodGet, "/site/blog/2020/a%20b/c", http.StatusFound, "/site/news/2020/a%20b/c", ""},
+		{http.MethodGet, "/site/app/x/y", http.StatusOK, "", "app/index.html"},
+		{http.MethodGet, "/site/kept.html", http.StatusOK, "", "kept.html"},
+		{http.MethodGet, "/site/forced.html", http.StatusMovedPermanently, "/site/x", ""},
+		{http.MethodGet, "/site/docs/q", http.StatusNotFound, "", "404.html"},
+		{http.MethodPost, "/site/docs/q", http.StatusNotFound, "", "404.html"},
+		{http.MethodGet, "/site/missing/q", http.StatusNotFound, "", "404 Not Found\n"},
+	}
+	for _, test := range tests {
+		w := doRequest(handler, test.method, test.target)
+		if w.Code != test.status || w.Header().Get(header.Location) != test.location {
+			t.Errorf("%s %s: got %d %q, want %d %q", test.method, test.target, w.Code, w.Header().Get(header.Location), test.status, test.location)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s: got %q, want %q", test.method, test.target, w.Body.String(), test.body)
+		}
+	}
+}
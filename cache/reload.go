@@ -0,0 +1,255 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"github.com/nelkinda/http-go/mimetype"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// ReloadSummary reports the changes made by Reload.
+type ReloadSummary struct {
+
+	// The URIs of the entries which were updated because their file changed.
+	Updated []string
+
+	// The URIs of the entries which were added because a new file appeared in a directory loaded with LoadDir.
+	Added []string
+
+	// The URIs of the entries which were removed because their file was deleted.
+	Removed []string
+}
+
+// Changed returns true if Reload changed the cache.
+func (s ReloadSummary) Changed() bool {
+	return len(s.Updated) > 0 || len(s.Added) > 0 || len(s.Removed) > 0
+}
+
+// Reload checks the files of all entries with a Filename, and the directories loaded with LoadDir, for changes.
+// Entries whose file changed are re-read, re-compressed and atomically replaced.
+// Entries whose file was deleted are removed.
+// New files in directories loaded with LoadDir are added.
+// Reload continues after errors, and returns the first error encountered.
+func (c *Cache) Reload() (ReloadSummary, error) {
+	var summary ReloadSummary
+	var firstErr error
+	report := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, entry := range c.Entries() {
+		if entry.Filename == "" {
+			continue
+		}
+		info, err := os.Stat(entry.Filename)
+		if os.IsNotExist(err) {
+			if c.removeIfSame(entry) {
+				summary.Removed = append(summary.Removed, entry.URI)
+			}
+			continue
+		}
+		if err != nil {
+			report(err)
+			continue
+		}
+		stale, err := entry.isStale(info)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if !stale {
+			continue
+		}
+		updated, err := entry.reload(info)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if updated.asset != "" && updated.ETag != entry.ETag {
+			// The content changed, so it needs a new fingerprint.
+			updated.URI = updated.asset
+			summary.Updated = append(summary.Updated, c.Fingerprint(updated))
+		} else if c.replaceIfSame(entry, updated) {
+			summary.Updated = append(summary.Updated, entry.URI)
+		}
+	}
+	for dir, options := range c.loadedDirs() {
+		options := options
+		fsys := os.DirFS(dir)
+		err := options.walk(fsys, func(name string, d fs.DirEntry) error {
+			if c.isLoaded(options.Prefix + name) {
+				return nil
+			}
+			entry, err := options.loadEntry(fsys, dir, name, d)
+			if err != nil {
+				report(err)
+				return nil
+			}
+			if entry.ContentType == "" {
+				entry.ContentType = mimetype.ApplicationOctetStream
+			}
+			summary.Added = append(summary.Added, c.addLoaded(entry, name, options))
+			return nil
+		})
+		if err != nil {
+			report(err)
+		}
+	}
+	return summary, firstErr
+}
+
+func Reload() (ReloadSummary, error) {
+	return GlobalCache.Reload()
+}
+
+// isStale returns true if the file described by info differs from the file from which this entry was loaded.
+// If the modification time and the size are unchanged, the content of the file is compared with the digest of this entry,
+// as a file may be changed again within the resolution of the modification time of the file system.
+func (e *Entry) isStale(info os.FileInfo) (bool, error) {
+	if e.LastModified == nil || !info.ModTime().UTC().Equal(*e.LastModified) || info.Size() != e.bodySize() {
+		return true, nil
+	}
+	digest, ok := e.digests[Identity]
+	if !ok {
+		return true, nil
+	}
+	file, err := os.Open(e.Filename)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return false, err
+	}
+	return !bytes.Equal(hash.Sum(nil), digest), nil
+}
+
+// reload returns a copy of this entry with the current content of its file.
+// If only the modification time changed, the compressed bodies are reused.
+func (e *Entry) reload(info os.FileInfo) (*Entry, error) {
+	if e.file != nil {
+		return e.reloadFile()
+	}
+	body, err := ioutil.ReadFile(e.Filename)
+	if err != nil {
+		return nil, err
+	}
+	modTime := info.ModTime().UTC()
+	updated := *e
+	updated.LastModified = &modTime
+	if etag := computeETag(body); etag != e.ETag {
+		updated.Body = body
+		updated.GzipBody = nil
+		updated.BrotliBody = nil
+		updated.ZstdBody = nil
+		updated.ETag = ""
+		updated.Integrity = nil
+		updated.digests = nil
+	}
+	return &updated, nil
+}
+
+// reloadFile returns a copy of this file-backed entry with the current metadata of its file.
+func (e *Entry) reloadFile() (*Entry, error) {
+	fresh, err := NewFileEntry(e.Filename, e.URI, e.ContentType, e.file.mapped != nil)
+	if err != nil {
+		return nil, err
+	}
+	updated := *e
+	updated.LastModified = fresh.LastModified
+	updated.ETag = fresh.ETag
+	updated.Integrity = fresh.Integrity
+	updated.digests = fresh.digests
+	updated.file = fresh.file
+	return &updated, nil
+}
+
+// loadedDirs returns a copy of the directories loaded with LoadDir.
+func (c *Cache) loadedDirs() map[string]LoadOptions {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	dirs := make(map[string]LoadOptions, len(c.dirs))
+	for dir, options := range c.dirs {
+		dirs[dir] = options
+	}
+	return dirs
+}
+
+// replaceIfSame replaces old with updated, unless the entry for the URI of old was replaced or removed meanwhile.
+func (c *Cache) replaceIfSame(old *Entry, updated *Entry) bool {
+	updated.prepare()
+	c.mutex.Lock()
+	if c.entries[old.URI] != old {
+		c.mutex.Unlock()
+		return false
+	}
+	c.put(updated)
+	evicted := c.evict()
+	c.mutex.Unlock()
+	c.notifyEvicted(evicted)
+	return true
+}
+
+// removeIfSame removes old, unless the entry for the URI of old was replaced or removed meanwhile.
+func (c *Cache) removeIfSame(old *Entry) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries[old.URI] != old {
+		return false
+	}
+	c.delete(old.URI)
+	return true
+}
+
+// Watcher polls the files of a cache for changes.
+type Watcher struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// DefaultWatchInterval is the interval used by Watch if the given interval is not positive.
+const DefaultWatchInterval = 2 * time.Second
+
+// Watch starts a goroutine which calls Reload every interval until the returned Watcher is stopped.
+// If interval is 0 or negative, DefaultWatchInterval is used.
+// Polling works on every platform and file system, including network file systems.
+// If report is not nil, it is called after every Reload which changed the cache or failed.
+func (c *Cache) Watch(interval time.Duration, report func(summary ReloadSummary, err error)) *Watcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	w := &Watcher{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				summary, err := c.Reload()
+				if report != nil && (err != nil || summary.Changed()) {
+					report(summary, err)
+				}
+			}
+		}
+	}()
+	return w
+}
+
+func Watch(interval time.Duration, report func(summary ReloadSummary, err error)) *Watcher {
+	return GlobalCache.Watch(interval, report)
+}
+
+// Stop stops the watcher and waits until a Reload in progress has finished.
+func (w *Watcher) Stop() {
+	close(w.stop)
+	<-w.done
+}
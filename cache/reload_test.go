@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile writes body to the file name in dir, with a modification time which differs from earlier writes.
+func writeFile(t *testing.T, dir string, name string, body string, modTime time.Time) {
+	t.Helper()
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filename, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFile(t, dir, "a.html", "a", modTime)
+	writeFile(t, dir, "b.css", "b", modTime)
+	c := New()
+	if _, err := c.LoadDir(dir, LoadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if summary, err := c.Reload(); err != nil || summary.Changed() {
+		t.Fatalf("got %v, %v without changes", summary, err)
+	}
+
+	writeFile(t, dir, "a.html", "aa", modTime.Add(time.Second))
+	if err := os.Remove(filepath.Join(dir, "b.css")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "c.js", "c", modTime)
+	summary, err := c.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(summary.Updated, summary.Added, summary.Removed); got != "[a.html] [c.js] [b.css]" {
+		t.Errorf("got summary %s", got)
+	}
+	if entry, ok := c.Get("a.html"); !ok || string(entry.Body) != "aa" || entry.ETag != computeETag([]byte("aa")) || !entry.LastModified.Equal(modTime.Add(time.Second)) {
+		t.Errorf("a.html was not updated: %v", entry)
+	}
+	if _, ok := c.Get("b.css"); ok {
+		t.Error("b.css was not removed")
+	}
+	if entry, ok := c.Get("c.js"); !ok || string(entry.Body) != "c" {
+		t.Error("c.js was not added")
+	}
+}
+
+func TestReloadSameModTimeAndSize(t *testing.T) {
+	for _, threshold := range []int64{0, 1} {
+		dir := t.TempDir()
+		modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+		writeFile(t, dir, "a.txt", "old", modTime)
+		c := New()
+		if _, err := c.LoadDir(dir, LoadOptions{FileThreshold: threshold}); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, dir, "a.txt", "new", modTime)
+		summary, err := c.Reload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(summary.Updated); got != "[a.txt]" {
+			t.Errorf("FileThreshold %d: got updated %s, want the changed content detected", threshold, got)
+		}
+		entry, _ := c.Get("a.txt")
+		if body, err := entry.readBody(); err != nil || string(body) != "new" || entry.ETag != computeETag([]byte("new")) {
+			t.Errorf("FileThreshold %d: got %q, %v, ETag %s", threshold, body, err, entry.ETag)
+		}
+		if summary, err := c.Reload(); err != nil || summary.Changed() {
+			t.Errorf("FileThreshold %d: got %v, %v without changes", threshold, summary, err)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFile(t, dir, "a.html", "a", modTime)
+	c := New()
+	if _, err := c.LoadDir(dir, LoadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	reports := make(chan ReloadSummary, 1)
+	watcher := c.Watch(10*time.Millisecond, func(summary ReloadSummary, err error) {
+		select {
+		case reports <- summary:
+		default:
+		}
+	})
+	defer watcher.Stop()
+	writeFile(t, dir, "a.html", "aa", modTime.Add(time.Second))
+	select {
+	case summary := <-reports:
+		if fmt.Sprint(summary.Updated) != "[a.html]" {
+			t.Errorf("got summary %v", summary)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload reported")
+	}
+}
+
+func TestWatchDefaultInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		New().Watch(interval, nil).Stop()
+	}
+}
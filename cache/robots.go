@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// The header field with which entries with NoIndex ask search engines not to index them.
+	xRobotsTag = "X-Robots-Tag"
+
+	// The URI of robots.txt as per RFC 9309.
+	robotsURI = "robots.txt"
+
+	// The URI of security.txt as per RFC 9116.
+	securityTxtURI = ".well-known/security.txt"
+
+	// The content type of robots.txt and security.txt.
+	textPlainUTF8 = "text/plain; charset=utf-8"
+)
+
+// Robots describes a robots.txt file as per RFC 9309.
+type Robots struct {
+
+	// The groups of rules.
+	// If there are no groups, all crawlers are allowed everything.
+	Groups []RobotsGroup
+
+	// The absolute URLs of sitemaps, like "https://example.com/sitemap.xml".
+	Sitemaps []string
+}
+
+// RobotsGroup is a group of rules which applies to the crawlers with the listed user agents.
+type RobotsGroup struct {
+
+	// The product tokens of the crawlers to which this group applies, like "Googlebot", or "*" for all crawlers.
+	UserAgents []string
+
+	// The paths which the crawlers may access, like "/public/".
+	Allow []string
+
+	// The paths which the crawlers must not access, like "/private/".
+	Disallow []string
+
+	// The minimum time between two requests of a crawler, or 0 for none.
+	// Crawl-delay is not part of RFC 9309, and is ignored by some crawlers.
+	CrawlDelay time.Duration
+}
+
+// String returns the content of this robots.txt.
+func (r *Robots) String() string {
+	var b strings.Builder
+	groups := r.Groups
+	if len(groups) == 0 {
+		groups = []RobotsGroup{{UserAgents: []string{"*"}}}
+	}
+	for i, group := range groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for _, userAgent := range group.UserAgents {
+			b.WriteString("User-agent: " + userAgent + "\n")
+		}
+		for _, allow := range group.Allow {
+			b.WriteString("Allow: " + allow + "\n")
+		}
+		for _, disallow := range group.Disallow {
+			b.WriteString("Disallow: " + disallow + "\n")
+		}
+		if len(group.Allow) == 0 && len(group.Disallow) == 0 {
+			// A group needs at least one rule, an empty Disallow allows everything.
+			b.WriteString("Disallow:\n")
+		}
+		if group.CrawlDelay > 0 {
+			b.WriteString("Crawl-delay: " + strconv.FormatFloat(group.CrawlDelay.Seconds(), 'f', -1, 64) + "\n")
+		}
+	}
+	if len(r.Sitemaps) > 0 {
+		b.WriteString("\n")
+		for _, sitemap := range r.Sitemaps {
+			b.WriteString("Sitemap: " + sitemap + "\n")
+		}
+	}
+	return b.String()
+}
+
+// AddRobots adds robots.txt to this cache, and returns its entry.
+func (c *Cache) AddRobots(robots Robots) *Entry {
+	entry := newTextEntry(robotsURI, robots.String())
+	c.Add(entry)
+	return entry
+}
+
+func AddRobots(robots Robots) *Entry {
+	return GlobalCache.AddRobots(robots)
+}
+
+// SecurityTxt describes a security.txt file as per RFC 9116.
+// Each field may be listed multiple times, except Expires and PreferredLanguages.
+type SecurityTxt struct {
+
+	// The URIs for contacting about security issues, like "mailto:security@example.com" or "https://example.com/security".
+	// At least one contact is required.
+	Contact []string
+
+	// The time after which the content of security.txt is considered stale, required.
+	// RFC 9116 recommends less than a year into the future.
+	Expires time.Time
+
+	// The URIs of keys for encrypted communication.
+	Encryption []string
+
+	// The URIs of pages recognizing security researchers.
+	Acknowledgments []string
+
+	// The language tags of the preferred languages for security reports, like "en" or "de".
+	PreferredLanguages []string
+
+	// The URIs under which this security.txt is published.
+	Canonical []string
+
+	// The URIs of the security policy.
+	Policy []string
+
+	// The URIs of security-related job positions.
+	Hiring []string
+}
+
+// String returns the content of this security.txt.
+func (s *SecurityTxt) String() string {
+	var b strings.Builder
+	writeFields := func(name string, values []string) {
+		for _, value := range values {
+			b.WriteString(name + ": " + value + "\n")
+		}
+	}
+	writeFields("Contact", s.Contact)
+	b.WriteString("Expires: " + s.Expires.UTC().Format(time.RFC3339) + "\n")
+	writeFields("Encryption", s.Encryption)
+	writeFields("Acknowledgments", s.Acknowledgments)
+	if len(s.PreferredLanguages) > 0 {
+		b.WriteString("Preferred-Languages: " + strings.Join(s.PreferredLanguages, ", ") + "\n")
+	}
+	writeFields("Canonical", s.Canonical)
+	writeFields("Policy", s.Policy)
+	writeFields("Hiring", s.Hiring)
+	return b.String()
+}
+
+// AddSecurityTxt adds /.well-known/security.txt to this cache, and returns its entry.
+// It returns an error if the required fields Contact or Expires are missing, or if Expires is in the past.
+func (c *Cache) AddSecurityTxt(securityTxt SecurityTxt) (*Entry, error) {
+	if len(securityTxt.Contact) == 0 {
+		return nil, errors.New("security.txt requires at least one Contact")
+	}
+	if !securityTxt.Expires.After(time.Now()) {
+		return nil, errors.New("security.txt requires an Expires in the future")
+	}
+	entry := newTextEntry(securityTxtURI, securityTxt.String())
+	c.Add(entry)
+	return entry, nil
+}
+
+func AddSecurityTxt(securityTxt SecurityTxt) (*Entry, error) {
+	return GlobalCache.AddSecurityTxt(securityTxt)
+}
+
+// newTextEntry returns a plain text entry generated now.
+func newTextEntry(uri string, text string) *Entry {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &Entry{URI: uri, Body: []byte(text), ContentType: textPlainUTF8, LastModified: &now}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"github.com/nelkinda/http-go/header"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRobots(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots Robots
+		want   string
+	}{
+		{"empty", Robots{}, "User-agent: *\nDisallow:\n"},
+		{
+			"groups and sitemaps",
+			Robots{
+				Groups: []RobotsGroup{
+					{UserAgents: []string{"*"}, Allow: []string{"/admin/public/"}, Disallow: []string{"/admin/"}, CrawlDelay: 1500 * time.Millisecond},
+					{UserAgents: []string{"GPTBot", "CCBot"}, Disallow: []string{"/"}},
+				},
+				Sitemaps: []string{"https://example.com/sitemap.xml"},
+			},
+			"User-agent: *\nAllow: /admin/public/\nDisallow: /admin/\nCrawl-delay: 1.5\n\nUser-agent: GPTBot\nUser-agent: CCBot\nDisallow: /\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+	}
+	for _, test := range tests {
+		if got := test.robots.String(); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAddRobots(t *testing.T) {
+	c := New()
+	c.AddRobots(Robots{})
+	w := doRequest(c.CacheHandler(nil), http.MethodGet, "/robots.txt")
+	if w.Code != http.StatusOK || w.Body.String() != "User-agent: *\nDisallow:\n" || w.Header().Get(header.ContentType) != textPlainUTF8 {
+		t.Errorf("got %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestSecurityTxt(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	securityTxt := SecurityTxt{
+		Contact:            []string{"mailto:security@example.com", "https://example.com/security"},
+		Expires:            expires,
+		PreferredLanguages: []string{"en", "de"},
+		Canonical:          []string{"https://example.com/.well-known/security.txt"},
+	}
+	want := "Contact: mailto:security@example.com\nContact: https://example.com/security\nExpires: 2030-01-02T03:04:05Z\nPreferred-Languages: en, de\nCanonical: https://example.com/.well-known/security.txt\n"
+	if got := securityTxt.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAddSecurityTxt(t *testing.T) {
+	c := New()
+	if _, err := c.AddSecurityTxt(SecurityTxt{Expires: time.Now().Add(time.Hour)}); err == nil {
+		t.Error("got no error without Contact")
+	}
+	if _, err := c.AddSecurityTxt(SecurityTxt{Contact: []string{"mailto:security@example.com"}, Expires: time.Now().Add(-time.Hour)}); err == nil {
+		t.Error("got no error for an Expires in the past")
+	}
+	if entries, _ := c.Size(); entries != 0 {
+		t.Error("an invalid security.txt was added")
+	}
+	if _, err := c.AddSecurityTxt(SecurityTxt{Contact: []string{"mailto:security@example.com"}, Expires: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if w := doRequest(c.CacheHandler(nil), http.MethodGet, "/.well-known/security.txt"); w.Code != http.StatusOK {
+		t.Errorf("got status %d", w.Code)
+	}
+}
+
+func TestNoIndex(t *testing.T) {
+	c := New()
+	c.Add(&Entry{URI: "secret.html", Body: []byte("x"), ContentType: "text/html", NoIndex: true})
+	if w := doRequest(c.CacheHandler(nil), http.MethodGet, "/secret.html"); w.Header().Get(xRobotsTag) != "noindex" {
+		t.Errorf("got X-Robots-Tag %q", w.Header().Get(xRobotsTag))
+	}
+}
@@ -0,0 +1,358 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SitemapMaxURLs is the maximum number of URLs in a single sitemap as per the sitemap protocol.
+	SitemapMaxURLs = 50000
+
+	// SitemapMaxBytes is the maximum size of a single uncompressed sitemap as per the sitemap protocol.
+	SitemapMaxBytes = 50 * 1024 * 1024
+
+	// The name of the sitemap, or of the sitemap index if the sitemap is split.
+	sitemapName = "sitemap.xml"
+
+	// The de-facto standard header field of reverse proxies for the scheme of the original request.
+	xForwardedProto = "X-Forwarded-Proto"
+)
+
+// SitemapOptions configures the sitemaps generated by Sitemap and SitemapHandler.
+type SitemapOptions struct {
+
+	// The scheme and host prepended to the URIs of the entries, like "https://example.com".
+	// If empty, it is the host of the request with the scheme forwarded by a reverse proxy, or https.
+	BaseURL string
+
+	// The maximum number of URLs per sitemap, or 0 for SitemapMaxURLs.
+	MaxURLs int
+
+	// The maximum size of an uncompressed sitemap in bytes, or 0 for SitemapMaxBytes.
+	MaxBytes int
+
+	// The IndexFile of the Handler which serves the entries, see HandlerOptions.
+	// Entries for the index file are listed with the URL of their directory, to which the Handler redirects.
+	IndexFile string
+
+	// The Prefix of the Handler which serves the entries, see HandlerOptions.
+	// It is prepended to the paths of the entries.
+	Prefix string
+}
+
+// Sitemap returns the sitemap of the HTML and XHTML entries of this cache, except those with NoIndex, redirects, and those stored by a Proxy or Memoize, as per https://www.sitemaps.org/protocol.html.
+// The URLs are sorted, and their host is taken from r, with the scheme forwarded by a reverse proxy, or https.
+// Entries for DefaultIndexFile are listed with the URL of their directory, as CacheHandler serves them.
+// If the entries exceed the limits of a single sitemap, the sitemap index is returned,
+// and the sitemaps it references are only available through SitemapHandler.
+func (c *Cache) Sitemap(r *http.Request) string {
+	options := SitemapOptions{IndexFile: DefaultIndexFile}
+	return string(c.generateSitemaps(options.baseURL(r), "/", options).files[sitemapName].Body)
+}
+
+func Sitemap(r *http.Request) string {
+	return GlobalCache.Sitemap(r)
+}
+
+// SitemapHandler returns a handler that serves the sitemap of the HTML and XHTML entries of this cache, except those with NoIndex, redirects, and those stored by a Proxy or Memoize.
+// The handler serves requests for sitemap.xml, and if the sitemap is split into a sitemap index and several sitemaps,
+// for sitemap-1.xml, sitemap-2.xml and so on, in the directory under which it is registered, usually the root.
+// Every sitemap is also available gzip-compressed with the additional extension .gz, like sitemap.xml.gz, which is served without further content coding.
+// The sitemaps are served like entries, with ETag, compression and conditional requests,
+// and are only regenerated after entries were added to or removed from the cache.
+func (c *Cache) SitemapHandler(options SitemapOptions) http.Handler {
+	return &sitemapHandler{cache: c, options: options}
+}
+
+func SitemapHandler(options SitemapOptions) http.Handler {
+	return GlobalCache.SitemapHandler(options)
+}
+
+// sitemapHandler serves the sitemaps of a cache.
+type sitemapHandler struct {
+	cache   *Cache
+	options SitemapOptions
+	mutex   sync.Mutex
+	current *sitemaps
+}
+
+// sitemaps are the sitemap files generated for a generation of a cache.
+type sitemaps struct {
+	generation uint64
+	baseURL    string
+	dir        string
+
+	// The sitemap files by name, as entries.
+	files map[string]*Entry
+}
+
+func (h *sitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dir, name := path.Split(r.URL.Path)
+	baseURL := h.options.baseURL(r)
+	h.mutex.Lock()
+	current := h.current
+	if current == nil || current.generation != h.cache.currentGeneration() || current.baseURL != baseURL || current.dir != dir {
+		current = h.cache.generateSitemaps(baseURL, dir, h.options)
+		h.current = current
+	}
+	h.mutex.Unlock()
+	if file, ok := current.files[name]; ok {
+		file.serve(w, r, h.cache, "", false)
+	} else {
+		h.cache.ServeError(w, r, http.StatusNotFound)
+	}
+}
+
+// currentGeneration returns the generation of this cache.
+func (c *Cache) currentGeneration() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.generation
+}
+
+// baseURL returns the configured base URL, or the host of r with the scheme forwarded by a reverse proxy, or https.
+// The scheme is not derived from r.TLS, as servers behind a TLS-terminating proxy which does not forward the scheme are common,
+// and a sitemap listing http URLs for a site served with https would be wrong.
+func (o *SitemapOptions) baseURL(r *http.Request) string {
+	if o.BaseURL != "" {
+		return strings.TrimSuffix(o.BaseURL, "/")
+	}
+	scheme := forwardedScheme(r)
+	if scheme == "" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// requestScheme returns the scheme with which the client sent r, taking reverse proxies into account.
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if scheme := forwardedScheme(r); scheme != "" {
+		return scheme
+	}
+	return "http"
+}
+
+// forwardedScheme returns the scheme http or https of the original request as forwarded by a reverse proxy in r, or "" if none.
+func forwardedScheme(r *http.Request) string {
+	var proto string
+	for _, element := range strings.Split(strings.Join(r.Header.Values(header.Forwarded), ";"), ";") {
+		if parameter := strings.TrimSpace(element); len(parameter) > 6 && strings.EqualFold(parameter[:6], "proto=") {
+			proto = strings.Trim(parameter[6:], `"`)
+			break
+		}
+	}
+	if proto == "" {
+		proto = strings.TrimSpace(strings.Split(r.Header.Get(xForwardedProto), ",")[0])
+	}
+	switch proto = strings.ToLower(proto); proto {
+	case "http", "https":
+		return proto
+	default:
+		return ""
+	}
+}
+
+// generateSitemaps generates the sitemaps of this cache for the given base URL and directory of the sitemaps.
+func (c *Cache) generateSitemaps(baseURL string, dir string, options SitemapOptions) *sitemaps {
+	maxURLs := options.MaxURLs
+	if maxURLs <= 0 {
+		maxURLs = SitemapMaxURLs
+	}
+	maxBytes := options.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = SitemapMaxBytes
+	}
+	generation := c.currentGeneration()
+
+	var parts []*sitemapPart
+	part := newSitemapPart()
+	entries := c.Entries()
+	sort.SliceStable(entries, func(i, j int) bool {
+		return sitemapPath(entries[i].URI, options) < sitemapPath(entries[j].URI, options)
+	})
+	for _, entry := range entries {
+		if !entry.inSitemap() {
+			continue
+		}
+		element := entry.sitemapURL(baseURL, options)
+		if part.count > 0 && (part.count >= maxURLs || part.body.Len()+len(element)+len(urlsetEnd) > maxBytes) {
+			parts = append(parts, part)
+			part = newSitemapPart()
+		}
+		part.add(element, entry.LastModified)
+	}
+	parts = append(parts, part)
+
+	s := &sitemaps{generation: generation, baseURL: baseURL, dir: dir, files: make(map[string]*Entry)}
+	uriPrefix := strings.TrimPrefix(dir, "/")
+	if len(parts) == 1 {
+		s.add(uriPrefix, sitemapName, parts[0].finish(), parts[0].lastModified)
+		return s
+	}
+	index := bytes.NewBufferString(xml.Header + sitemapindexStart)
+	var lastModified *time.Time
+	for i, part := range parts {
+		name := "sitemap-" + strconv.Itoa(i+1) + ".xml"
+		s.add(uriPrefix, name, part.finish(), part.lastModified)
+		index.WriteString("<sitemap><loc>")
+		writeEscaped(index, baseURL+escapePath(dir+name))
+		index.WriteString("</loc>")
+		writeLastModified(index, part.lastModified)
+		index.WriteString("</sitemap>\n")
+		lastModified = latest(lastModified, part.lastModified)
+	}
+	index.WriteString(sitemapindexEnd)
+	s.add(uriPrefix, sitemapName, index.Bytes(), lastModified)
+	return s
+}
+
+// add adds the sitemap file name and its gzip-compressed variant.
+// The gzip-compressed variant is served as is, without further content codings.
+func (s *sitemaps) add(uriPrefix string, name string, body []byte, lastModified *time.Time) {
+	file := &Entry{URI: uriPrefix + name, Body: body, ContentType: mimetype.ApplicationXml, LastModified: lastModified}
+	file.prepare()
+	s.files[name] = file
+	compressed := &Entry{URI: uriPrefix + name + ".gz", Body: file.GzipBody, ContentType: mimetype.ApplicationGzip, LastModified: lastModified}
+	compressed.prepareIdentity()
+	s.files[name+".gz"] = compressed
+}
+
+const (
+	urlsetStart       = `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">` + "\n"
+	urlsetEnd         = "</urlset>\n"
+	sitemapindexStart = `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	sitemapindexEnd   = "</sitemapindex>\n"
+)
+
+// sitemapPart is a single sitemap of a possibly split sitemap.
+type sitemapPart struct {
+	body         *bytes.Buffer
+	count        int
+	lastModified *time.Time
+}
+
+func newSitemapPart() *sitemapPart {
+	return &sitemapPart{body: bytes.NewBufferString(xml.Header + urlsetStart)}
+}
+
+func (p *sitemapPart) add(element []byte, lastModified *time.Time) {
+	p.body.Write(element)
+	p.count++
+	p.lastModified = latest(p.lastModified, lastModified)
+}
+
+func (p *sitemapPart) finish() []byte {
+	p.body.WriteString(urlsetEnd)
+	return p.body.Bytes()
+}
+
+// inSitemap returns true if this entry is a page to be listed in the sitemap.
+func (e *Entry) inSitemap() bool {
+	if e.NoIndex || e.Redirect != "" || e.freshness != nil {
+		return false
+	}
+	switch mediaType(e.ContentType) {
+	case mimetype.TextHtml, mimetype.ApplicationXhtmlXml:
+		return true
+	default:
+		return false
+	}
+}
+
+// sitemapURL returns the url element of this entry for the sitemap, with the paths of the entries as per options.
+func (e *Entry) sitemapURL(baseURL string, options SitemapOptions) []byte {
+	var element bytes.Buffer
+	element.WriteString("<url><loc>")
+	writeEscaped(&element, baseURL+sitemapPath(e.URI, options))
+	element.WriteString("</loc>")
+	writeLastModified(&element, e.LastModified)
+	if e.ChangeFreq != "" {
+		element.WriteString("<changefreq>")
+		writeEscaped(&element, e.ChangeFreq)
+		element.WriteString("</changefreq>")
+	}
+	if e.Priority > 0 {
+		element.WriteString("<priority>" + strconv.FormatFloat(e.Priority, 'f', 1, 64) + "</priority>")
+	}
+	alternates := e.alternates()
+	languages := make([]string, 0, len(alternates))
+	for language := range alternates {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	for _, language := range languages {
+		element.WriteString(`<xhtml:link rel="alternate" hreflang="`)
+		writeEscaped(&element, language)
+		element.WriteString(`" href="`)
+		writeEscaped(&element, baseURL+sitemapPath(alternates[language], options))
+		element.WriteString(`"/>`)
+	}
+	element.WriteString("</url>\n")
+	return element.Bytes()
+}
+
+// sitemapPath returns the escaped absolute path of uri under the Prefix of options, or of its directory if it is the IndexFile of options.
+func sitemapPath(uri string, options SitemapOptions) string {
+	if indexFile := options.IndexFile; indexFile != "" && (uri == indexFile || strings.HasSuffix(uri, "/"+indexFile)) {
+		uri = strings.TrimSuffix(uri, indexFile)
+	}
+	return prefixedPath(options.Prefix, uri)
+}
+
+// alternates returns the URIs of the translations of this entry by language tag, including this entry itself.
+func (e *Entry) alternates() map[string]string {
+	alternates := make(map[string]string)
+	for _, variant := range e.Variants {
+		if variant.Language != "" && variant.URI != "" {
+			alternates[variant.Language] = variant.URI
+		}
+	}
+	for language, uri := range e.Alternates {
+		alternates[language] = uri
+	}
+	if len(alternates) > 0 && e.Language != "" {
+		alternates[e.Language] = e.URI
+	}
+	return alternates
+}
+
+// writeLastModified writes the lastmod element for lastModified, if not nil.
+func writeLastModified(buffer *bytes.Buffer, lastModified *time.Time) {
+	if lastModified != nil {
+		buffer.WriteString("<lastmod>" + lastModified.UTC().Format(time.RFC3339) + "</lastmod>")
+	}
+}
+
+// writeEscaped writes s escaped as XML character data.
+func writeEscaped(buffer *bytes.Buffer, s string) {
+	if err := xml.EscapeText(buffer, []byte(s)); err != nil {
+		panic(err)
+	}
+}
+
+// escapePath returns the URL-escaped form of an absolute path.
+func escapePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// latest returns the later of both times, either of which may be nil.
+func latest(a *time.Time, b *time.Time) *time.Time {
+	if a == nil || b != nil && b.After(*a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"github.com/nelkinda/http-go/header"
+	"github.com/nelkinda/http-go/mimetype"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sitemapLocs returns the loc elements of a sitemap or sitemap index.
+func sitemapLocs(t *testing.T, body string) []string {
+	t.Helper()
+	var document struct {
+		URLs     []string `xml:"url>loc"`
+		Sitemaps []string `xml:"sitemap>loc"`
+	}
+	if err := xml.Unmarshal([]byte(body), &document); err != nil {
+		t.Fatalf("invalid sitemap: %v\n%s", err, body)
+	}
+	return append(document.URLs, document.Sitemaps...)
+}
+
+func TestSitemap(t *testing.T) {
+	c := New()
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.Add(&Entry{URI: "a b&c.html", Body: []byte("x"), ContentType: "text/html; charset=utf-8", LastModified: &lastModified, ChangeFreq: "daily", Priority: 0.8})
+	c.Add(&Entry{URI: "en/", Body: []byte("x"), ContentType: "text/html", Language: "en", Alternates: map[string]string{"de": "de/"}})
+	c.Add(&Entry{URI: "page.xhtml", Body: []byte("x"), ContentType: "application/xhtml+xml"})
+	c.Add(&Entry{URI: "style.css", Body: []byte("x"), ContentType: "text/css"})
+	c.Add(&Entry{URI: "private.html", Body: []byte("x"), ContentType: "text/html", NoIndex: true})
+
+	sitemap := c.Sitemap(httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil))
+	if got := fmt.Sprint(sitemapLocs(t, sitemap)); got != "[https://example.com/a%20b&c.html https://example.com/en/ https://example.com/page.xhtml]" {
+		t.Errorf("got locs %s", got)
+	}
+	for _, want := range []string{
+		"<lastmod>2024-01-02T03:04:05Z</lastmod><changefreq>daily</changefreq><priority>0.8</priority>",
+		`<xhtml:link rel="alternate" hreflang="de" href="https://example.com/de/"/><xhtml:link rel="alternate" hreflang="en" href="https://example.com/en/"/>`,
+	} {
+		if !strings.Contains(sitemap, want) {
+			t.Errorf("sitemap lacks %s:\n%s", want, sitemap)
+		}
+	}
+
+	for _, fields := range [][]string{{xForwardedProto, "http"}, {header.Forwarded, "for=192.0.2.1;proto=http"}} {
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/sitemap.xml", nil)
+		r.Header.Set(fields[0], fields[1])
+		if locs := sitemapLocs(t, c.Sitemap(r)); !strings.HasPrefix(locs[0], "http://example.com/") {
+			t.Errorf("%s: got %s behind a reverse proxy without TLS", fields, locs[0])
+		}
+	}
+}
+
+func TestSitemapHandlerSplits(t *testing.T) {
+	c := New()
+	for i := 0; i < 5; i++ {
+		c.Add(&Entry{URI: fmt.Sprintf("p%d", i), Body: []byte("x"), ContentType: "text/html"})
+	}
+	handler := c.SitemapHandler(SitemapOptions{BaseURL: "https://example.com/", MaxURLs: 2})
+	tests := []struct {
+		path string
+		locs string
+	}{
+		{"/sitemap.xml", "[https://example.com/sitemap-1.xml https://example.com/sitemap-2.xml https://example.com/sitemap-3.xml]"},
+		{"/sitemap-1.xml", "[https://example.com/p0 https://example.com/p1]"},
+		{"/sitemap-3.xml", "[https://example.com/p4]"},
+	}
+	for _, test := range tests {
+		w := doRequest(handler, http.MethodGet, test.path)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d", test.path, w.Code)
+		}
+		if got := fmt.Sprint(sitemapLocs(t, w.Body.String())); got != test.locs {
+			t.Errorf("%s: got %s, want %s", test.path, got, test.locs)
+		}
+	}
+
+	w := doRequest(handler, http.MethodGet, "/sitemap-2.xml.gz")
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(reader)
+	if got := fmt.Sprint(sitemapLocs(t, string(body))); got != "[https://example.com/p2 https://example.com/p3]" {
+		t.Errorf("got %s from the compressed sitemap", got)
+	}
+
+	if w := doRequest(handler, http.MethodGet, "/sitemap-4.xml"); w.Code != http.StatusNotFound {
+		t.Errorf("got status %d for a missing sitemap", w.Code)
+	}
+}
+
+func TestSitemapHandlerRegenerates(t *testing.T) {
+	c := New()
+	c.Add(&Entry{URI: "a", Body: []byte("x"), ContentType: "text/html"})
+	handler := c.SitemapHandler(SitemapOptions{})
+	w := doRequest(handler, http.MethodGet, "/sitemap.xml")
+	etag := w.Header().Get(header.ETag)
+	if w := doRequest(handler, http.MethodGet, "/sitemap.xml", header.IfNoneMatch, etag); w.Code != http.StatusNotModified {
+		t.Errorf("got status %d for an unchanged sitemap", w.Code)
+	}
+	c.Add(&Entry{URI: "b", Body: []byte("x"), ContentType: "text/html"})
+	w = doRequest(handler, http.MethodGet, "/sitemap.xml", header.IfNoneMatch, etag)
+	if w.Code != http.StatusOK || fmt.Sprint(sitemapLocs(t, w.Body.String())) != "[https://example.com/a https://example.com/b]" {
+		t.Errorf("got %d %s after adding an entry", w.Code, w.Body.String())
+	}
+}
+
+func TestSitemapHandlerServesCompressedSitemapAsIs(t *testing.T) {
+	c := New()
+	recorder := &eventRecorder{}
+	c.SetRecorder(recorder)
+	c.Add(&Entry{URI: "a", Body: []byte("x"), ContentType: "text/html"})
+	handler := c.SitemapHandler(SitemapOptions{})
+	for _, accept := range []string{"", "gzip", "br, zstd"} {
+		w := doRequest(handler, http.MethodGet, "/sitemap.xml.gz", header.AcceptEncoding, accept)
+		if w.Code != http.StatusOK || w.Header().Get(header.ContentEncoding) != "" || w.Header().Get(header.ContentType) != mimetype.ApplicationGzip {
+			t.Fatalf("Accept-Encoding %q: got %d, Content-Encoding %q, Content-Type %q", accept, w.Code, w.Header().Get(header.ContentEncoding), w.Header().Get(header.ContentType))
+		}
+		reader, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(reader)
+		if got := fmt.Sprint(sitemapLocs(t, string(body))); got != "[https://example.com/a]" {
+			t.Errorf("Accept-Encoding %q: got %s", accept, got)
+		}
+	}
+	for _, event := range recorder.events {
+		if strings.HasPrefix(event, "hit ") {
+			t.Errorf("got %s for a sitemap", event)
+		}
+	}
+	if got := c.metrics.hits; got != 0 {
+		t.Errorf("got %d hits for sitemaps", got)
+	}
+}
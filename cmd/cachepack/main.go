@@ -0,0 +1,127 @@
+// Command cachepack packs a directory tree into a cache archive at build time,
+// so that a server loads it with cache.LoadArchive without compressing or hashing anything at startup.
+// The files are mapped to entries like with cache.LoadDir, and compressed at the best compression levels.
+//
+// Usage:
+//
+//	cachepack [flags] dir
+//
+// Example, which generates a package assets that embeds the archive:
+//
+//	cachepack -o assets/site.cachepack -go assets/assets.go -fingerprint '\.(css|js)$' public
+//
+// The server then loads the archive with:
+//
+//	summary, err := assets.Load(cache.GlobalCache)
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/nelkinda/http-go/cache"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"text/template"
+)
+
+func main() {
+	output := flag.String("o", "site.cachepack", "The archive file to write.")
+	goFile := flag.String("go", "", "A Go source file to write which embeds the archive, which then must be in the same directory.")
+	packageName := flag.String("package", "", "The package of the Go source file, by default the name of its directory.")
+	prefix := flag.String("prefix", "", "The prefix of the URIs of the entries.")
+	maxAge := flag.Duration("max-age", 0, "The maximum cache age of the entries.")
+	fingerprint := flag.String("fingerprint", "", "A regular expression for the relative paths of the files to fingerprint.")
+	hidden := flag.Bool("hidden", false, "Include files and directories whose name starts with a dot.")
+	fast := flag.Bool("fast", false, "Compress at the default instead of the best compression levels.")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		_, _ = fmt.Fprintln(os.Stderr, "usage: cachepack [flags] dir")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	options := cache.LoadOptions{Prefix: *prefix, MaxAge: *maxAge, IncludeHidden: *hidden}
+	if *fingerprint != "" {
+		pattern, err := regexp.Compile(*fingerprint)
+		exitOnError(err)
+		options.Fingerprint = pattern
+	}
+
+	c := cache.New()
+	summary, err := c.LoadFS(os.DirFS(flag.Arg(0)), options)
+	exitOnError(err)
+	for _, name := range summary.UnknownContentTypes {
+		_, _ = fmt.Fprintf(os.Stderr, "cachepack: unknown content type of %s\n", name)
+	}
+	exitOnError(writeArchive(c, *output, cache.ArchiveOptions{BestCompression: !*fast}))
+	if *goFile != "" {
+		exitOnError(writeGo(*goFile, *packageName, *output))
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "cachepack: %s\n", summary)
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "cachepack: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func writeArchive(c *cache.Cache, filename string, options cache.ArchiveOptions) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteArchive(file, options); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+var goTemplate = template.Must(template.New("go").Parse(`// Code generated by cachepack; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"bytes"
+	_ "embed"
+	"github.com/nelkinda/http-go/cache"
+)
+
+//go:embed {{.Archive}}
+var archive []byte
+
+// Load adds the entries of the embedded cache archive to c.
+func Load(c *cache.Cache) (cache.LoadSummary, error) {
+	return c.LoadArchive(bytes.NewReader(archive))
+}
+`))
+
+func writeGo(filename string, packageName string, archive string) error {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+	archiveDir, err := filepath.Abs(filepath.Dir(archive))
+	if err != nil {
+		return err
+	}
+	if dir != archiveDir {
+		return fmt.Errorf("the archive %s must be in the directory of %s", archive, filename)
+	}
+	if packageName == "" {
+		packageName = filepath.Base(dir)
+	}
+	var source bytes.Buffer
+	if err := goTemplate.Execute(&source, struct{ Package, Archive string }{packageName, filepath.Base(archive)}); err != nil {
+		return err
+	}
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, formatted, 0644)
+}
@@ -1,9 +1,11 @@
 module github.com/nelkinda/http-go
 
-go 1.14
+go 1.16
 
 require (
+	github.com/andybalholm/brotli v1.0.5
 	github.com/antchfx/xmlquery v1.2.4
 	github.com/iancoleman/strcase v0.0.0-20191112232945-16388991a334
+	github.com/klauspost/compress v1.11.13
 	golang.org/x/crypto v0.0.0-20200604202706-70a84ac30bf9
 )
@@ -19,6 +19,7 @@ const (
 	Allow = "Allow"
 	AltSvc = "Alt-Svc"
 	Authorization = "Authorization"
+	CDNCacheControl = "CDN-Cache-Control"
 	CacheControl = "Cache-Control"
 	Connection = "Connection"
 	ContentDisposition = "Content-Disposition"
@@ -44,6 +45,7 @@ const (
 	IfRange = "If-Range"
 	IfUnmodifiedSince = "If-Unmodified-Since"
 	IM = "IM"
+	KeepAlive = "Keep-Alive"
 	LastModified = "Last-Modified"
 	Link = "Link"
 	Location = "Location"
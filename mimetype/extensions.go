@@ -0,0 +1,67 @@
+package mimetype
+
+import "strings"
+
+// extensions maps common file name extensions to their mime types.
+var extensions = map[string]string{
+	".atom":        ApplicationAtomXml,
+	".avif":        ImageAvif,
+	".bmp":         ImageBmp,
+	".css":         TextCss,
+	".csv":         TextCsv,
+	".doc":         ApplicationMsword,
+	".epub":        ApplicationEpubZip,
+	".geojson":     ApplicationGeoJson,
+	".gif":         ImageGif,
+	".gz":          ApplicationGzip,
+	".htm":         TextHtml,
+	".html":        TextHtml,
+	".ico":         ImageVndMicrosoftIcon,
+	".ics":         TextCalendar,
+	".jpeg":        ImageJpeg,
+	".jpg":         ImageJpeg,
+	".js":          TextJavascript,
+	".json":        ApplicationJson,
+	".jsonld":      ApplicationLdJson,
+	".m4a":         AudioMp4,
+	".map":         ApplicationJson,
+	".md":          TextMarkdown,
+	".mjs":         TextJavascript,
+	".mp3":         AudioMpeg,
+	".mp4":         VideoMp4,
+	".oga":         AudioOgg,
+	".ogg":         AudioOgg,
+	".ogv":         VideoOgg,
+	".opus":        AudioOpus,
+	".otf":         FontOtf,
+	".pdf":         ApplicationPdf,
+	".png":         ImagePng,
+	".rss":         ApplicationRssXml,
+	".svg":         ImageSvgXml,
+	".tif":         ImageTiff,
+	".tiff":        ImageTiff,
+	".ttc":         FontCollection,
+	".ttf":         FontTtf,
+	".txt":         TextPlain,
+	".vtt":         TextVtt,
+	".wasm":        ApplicationWasm,
+	".weba":        AudioWebm,
+	".webm":        VideoWebm,
+	".webmanifest": ApplicationManifestJson,
+	".webp":        ImageWebp,
+	".woff":        FontWoff,
+	".woff2":       FontWoff2,
+	".xhtml":       ApplicationXhtmlXml,
+	".xml":         ApplicationXml,
+	".zip":         ApplicationZip,
+}
+
+// ByExtension returns the mime type for the given file name extension, like ".html", or "" if the extension is unknown.
+// The leading dot is optional, and the extension is matched case-insensitively.
+func ByExtension(ext string) string {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return extensions[ext]
+}
@@ -2,4 +2,28 @@ package mimetype
 
 const (
 	ApplicationHealthJson = "application/health+json"
+
+	// ApplicationManifestJson is the constant for the mime type "application/manifest+json" of Web App Manifests.
+	ApplicationManifestJson = "application/manifest+json"
+
+	// ApplicationRssXml is the constant for the widely used but unregistered mime type "application/rss+xml".
+	ApplicationRssXml = "application/rss+xml"
+
+	// ApplicationWasm is the constant for the mime type "application/wasm" of WebAssembly modules.
+	ApplicationWasm = "application/wasm"
+
+	// AudioWebm is the constant for the mime type "audio/webm".
+	AudioWebm = "audio/webm"
+
+	// ImageAvif is the constant for the mime type "image/avif".
+	ImageAvif = "image/avif"
+
+	// ImageWebp is the constant for the mime type "image/webp".
+	ImageWebp = "image/webp"
+
+	// TextVtt is the constant for the mime type "text/vtt" of WebVTT subtitles.
+	TextVtt = "text/vtt"
+
+	// VideoWebm is the constant for the mime type "video/webm".
+	VideoWebm = "video/webm"
 )